@@ -0,0 +1,90 @@
+package kinesiswriter
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// batchAdvisor observes flushes and logs tuning suggestions for the buffer options
+// once every sampleSize flushes.
+type batchAdvisor struct {
+	sampleSize  int
+	buffer      bufferConfig
+	maxInFlight int
+
+	mu            sync.Mutex
+	lastFlush     time.Time
+	flushes       int
+	records       int
+	intervals     time.Duration
+	fullBatches   int
+	duration      time.Duration
+	writeTimeouts int
+}
+
+func newBatchAdvisor(sampleSize int, buffer bufferConfig, maxInFlight int) *batchAdvisor {
+	return &batchAdvisor{sampleSize: sampleSize, buffer: buffer, maxInFlight: maxInFlight}
+}
+
+func (a *batchAdvisor) writeTimedOut() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.writeTimeouts++
+}
+
+func (a *batchAdvisor) observe(records int, duration time.Duration) {
+	now := time.Now()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.lastFlush.IsZero() {
+		a.intervals += now.Sub(a.lastFlush)
+	}
+	a.lastFlush = now
+	a.flushes++
+	a.records += records
+	a.duration += duration
+	if records >= int(a.buffer.recordWindow) {
+		a.fullBatches++
+	}
+	if a.flushes < a.sampleSize {
+		return
+	}
+	for _, s := range a.suggestions() {
+		log.Printf("batch advisor: %s", s)
+	}
+	a.flushes, a.records, a.intervals, a.fullBatches, a.duration, a.writeTimeouts = 0, 0, 0, 0, 0, 0
+}
+
+func (a *batchAdvisor) suggestions() []string {
+	var s []string
+	avgRecords := float64(a.records) / float64(a.flushes)
+	var avgInterval time.Duration
+	if a.flushes > 1 {
+		avgInterval = a.intervals / time.Duration(a.flushes-1)
+	}
+	avgDuration := a.duration / time.Duration(a.flushes)
+	if avgRecords <= 2 && avgInterval >= a.buffer.flushInterval/2 {
+		s = append(s, fmt.Sprintf("batches average %.1f records every %s; raise WithBufferFlushInterval to send fewer, larger PutRecords calls",
+			avgRecords, avgInterval.Round(time.Millisecond)))
+	}
+	if a.fullBatches*10 >= a.flushes*9 && int(a.buffer.recordWindow) < maxRequestRecords {
+		s = append(s, fmt.Sprintf("%d of %d batches filled the record window of %d; raise WithBufferRecordWindow up to %d",
+			a.fullBatches, a.flushes, a.buffer.recordWindow, maxRequestRecords))
+	}
+	if a.writeTimeouts > 0 {
+		raise := "raise WithBufferWriteTimeout"
+		// without a bound, more batches in flight would not help.
+		if a.maxInFlight > 0 {
+			raise += fmt.Sprintf(" or WithMaxInFlightBatches from %d", a.maxInFlight)
+		}
+		s = append(s, fmt.Sprintf("%d writes timed out waiting for the buffer while flushes took %s on average; %s",
+			a.writeTimeouts, avgDuration.Round(time.Millisecond), raise))
+	}
+	if avgDuration > a.buffer.flushTimeout/2 {
+		s = append(s, fmt.Sprintf("flushes took %s on average, close to the flush timeout of %s; raise WithBufferFlushTimeout or lower WithBufferRecordWindow",
+			avgDuration.Round(time.Millisecond), a.buffer.flushTimeout))
+	}
+	return s
+}
@@ -0,0 +1,29 @@
+package kinesiswriter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchAdvisorWriteTimeouts(t *testing.T) {
+	buffer := bufferConfig{recordWindow: 500, flushInterval: time.Second, flushTimeout: time.Minute}
+	tests := []struct {
+		name        string
+		maxInFlight int
+		want        string
+	}{
+		{name: "unbounded", want: "2 writes timed out waiting for the buffer while flushes took 10ms on average; raise WithBufferWriteTimeout"},
+		{name: "bounded", maxInFlight: 4, want: "2 writes timed out waiting for the buffer while flushes took 10ms on average; raise WithBufferWriteTimeout or WithMaxInFlightBatches from 4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newBatchAdvisor(10, buffer, tt.maxInFlight)
+			a.writeTimedOut()
+			a.writeTimedOut()
+			a.observe(100, 10*time.Millisecond)
+			assert.Equal(t, []string{tt.want}, a.suggestions())
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package kinesiswriter_test
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	kinesiswriter "github.com/mackee/go-kinesis-writer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterBatchAdvisor(t *testing.T) {
+	var out bytes.Buffer
+	log.SetOutput(&out)
+	defer log.SetOutput(os.Stderr)
+
+	writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+		kinesiswriter.WithKinesisClient(&successKinesisClient{}),
+		kinesiswriter.WithImmediateFlush(),
+		kinesiswriter.WithBufferFlushInterval(10*time.Millisecond),
+		kinesiswriter.WithBatchAdvisor(2),
+	)
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("record1\n"))
+	require.NoError(t, err)
+	assert.Empty(t, out.String())
+	time.Sleep(10 * time.Millisecond)
+	_, err = writer.Write([]byte("record2\n"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	assert.Contains(t, out.String(), "batch advisor: batches average 1.0 records every")
+	assert.Contains(t, out.String(), "raise WithBufferFlushInterval")
+}
@@ -0,0 +1,52 @@
+package kinesiswriter
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// announcementPrefix starts every encoding announcement record.
+const announcementPrefix = `{"kinesiswriter_encoding":`
+
+// encodingAnnouncementVersion is the version of the EncodingAnnouncement format.
+const encodingAnnouncementVersion = 1
+
+// EncodingAnnouncement describes how a producer encodes its records, so that consumers can
+// configure decoding from the stream itself when producers change settings.
+type EncodingAnnouncement struct {
+	// Version is the version of the announcement format, currently 1.
+	Version int `json:"kinesiswriter_encoding"`
+	// Codec is the name of the codec of WriteValue set with WithCodecName,
+	// "json" by default, or empty for a codec set with WithCodec.
+	Codec string `json:"codec"`
+	// Compression is the compression of records, always "none" for now.
+	Compression string `json:"compression"`
+	// Aggregation is the aggregation of records, always "none" for now.
+	Aggregation string `json:"aggregation"`
+	// WriterID is the ID of the writer set with WithWriterID, if any.
+	WriterID string `json:"writer_id,omitempty"`
+}
+
+// ParseEncodingAnnouncement decodes record if it is an encoding announcement record.
+func ParseEncodingAnnouncement(record []byte) (EncodingAnnouncement, bool) {
+	if !bytes.HasPrefix(record, []byte(announcementPrefix)) {
+		return EncodingAnnouncement{}, false
+	}
+	var a EncodingAnnouncement
+	if err := json.Unmarshal(record, &a); err != nil {
+		return EncodingAnnouncement{}, false
+	}
+	return a, true
+}
+
+// encodingAnnouncement returns the announcement record of the configuration.
+func (c *writerConfig) encodingAnnouncement() []byte {
+	record, _ := json.Marshal(EncodingAnnouncement{
+		Version:     encodingAnnouncementVersion,
+		Codec:       c.codecName,
+		Compression: "none",
+		Aggregation: "none",
+		WriterID:    c.writerID,
+	})
+	return record
+}
@@ -0,0 +1,61 @@
+package kinesiswriter_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	kinesiswriter "github.com/mackee/go-kinesis-writer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterEncodingAnnouncement(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  []kinesiswriter.WriterConfigOption
+		codec string
+	}{
+		{name: "default", codec: "json"},
+		{name: "named", opts: []kinesiswriter.WriterConfigOption{kinesiswriter.WithCodecName("gob")}, codec: "gob"},
+		{name: "custom", opts: []kinesiswriter.WriterConfigOption{kinesiswriter.WithCodec(kinesiswriter.GobCodec)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &successKinesisClient{}
+			writer, err := kinesiswriter.New(context.Background(), testStreamARN, append([]kinesiswriter.WriterConfigOption{
+				kinesiswriter.WithKinesisClient(client),
+				kinesiswriter.WithManualFlush(),
+				kinesiswriter.WithEncodingAnnouncement(time.Hour),
+			}, tt.opts...)...)
+			require.NoError(t, err)
+			_, err = writer.Write([]byte("record1\n"))
+			require.NoError(t, err)
+			require.NoError(t, writer.Close())
+
+			var records [][]byte
+			for _, input := range client.Inputs() {
+				for _, entry := range input.Records {
+					records = append(records, entry.Data)
+				}
+			}
+			require.Len(t, records, 2)
+			announcement, ok := kinesiswriter.ParseEncodingAnnouncement(records[0])
+			require.True(t, ok)
+			assert.Equal(t, kinesiswriter.EncodingAnnouncement{
+				Version:     1,
+				Codec:       tt.codec,
+				Compression: "none",
+				Aggregation: "none",
+			}, announcement)
+			_, ok = kinesiswriter.ParseEncodingAnnouncement(records[1])
+			assert.False(t, ok)
+		})
+	}
+
+	_, err := kinesiswriter.New(context.Background(), testStreamARN,
+		kinesiswriter.WithKinesisClient(&successKinesisClient{}),
+		kinesiswriter.WithCodecName("unknown"),
+	)
+	assert.Error(t, err)
+}
@@ -0,0 +1,130 @@
+package kinesiswriter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"log"
+	"path"
+	"sync"
+	"time"
+)
+
+const (
+	defaultArchiveInterval    = 5 * time.Minute
+	defaultArchiveObjectSize  = 64 * 1024 * 1024
+	defaultArchiveUploadLimit = time.Minute
+)
+
+// ArchiveUploader stores an archive object, typically with s3.Client.PutObject.
+type ArchiveUploader interface {
+	Upload(ctx context.Context, key string, body []byte) error
+}
+
+// archiver batches records into gzip compressed, newline delimited objects
+// keyed by time, e.g. prefix/2024/05/01/13/20240501T130000.000000000Z-0.gz.
+type archiver struct {
+	uploader      ArchiveUploader
+	prefix        string
+	interval      time.Duration
+	maxObjectSize int
+
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	gz      *gzip.Writer
+	startAt time.Time
+	raw     int
+	seq     int
+	uploads sync.WaitGroup
+	stop    chan struct{}
+	done    chan struct{}
+	closed  sync.Once
+}
+
+func newArchiver(uploader ArchiveUploader, prefix string, interval time.Duration) *archiver {
+	a := &archiver{
+		uploader:      uploader,
+		prefix:        prefix,
+		interval:      interval,
+		maxObjectSize: defaultArchiveObjectSize,
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	a.gz = gzip.NewWriter(&a.buf)
+	go a.run()
+	return a
+}
+
+func (a *archiver) run() {
+	defer close(a.done)
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			a.mu.Lock()
+			if a.raw > 0 && time.Since(a.startAt) >= a.interval {
+				a.rotate()
+			}
+			a.mu.Unlock()
+		case <-a.stop:
+			return
+		}
+	}
+}
+
+// add appends records to the current object, uploading it once it is large enough.
+func (a *archiver) add(records [][]byte) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.raw == 0 {
+		a.startAt = time.Now()
+	}
+	for _, record := range records {
+		// writes to a bytes.Buffer do not fail.
+		_, _ = a.gz.Write(record)
+		_, _ = a.gz.Write([]byte{'\n'})
+		a.raw += len(record) + 1
+	}
+	if a.raw >= a.maxObjectSize {
+		a.rotate()
+	}
+}
+
+// rotate uploads the current object in the background and starts a new one.
+// It must be called with mu held.
+func (a *archiver) rotate() {
+	_ = a.gz.Close()
+	body := bytes.Clone(a.buf.Bytes())
+	start := a.startAt.UTC()
+	key := path.Join(a.prefix, start.Format("2006/01/02/15"), fmt.Sprintf("%s-%d.gz", start.Format("20060102T150405.000000000Z"), a.seq))
+	a.seq++
+	a.buf.Reset()
+	a.gz.Reset(&a.buf)
+	a.raw = 0
+
+	a.uploads.Add(1)
+	go func() {
+		defer a.uploads.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), defaultArchiveUploadLimit)
+		defer cancel()
+		if err := a.uploader.Upload(ctx, key, body); err != nil {
+			log.Printf("failed to upload archive %s: %s", key, err)
+		}
+	}()
+}
+
+// close uploads the remaining records and waits for all uploads.
+func (a *archiver) close() {
+	a.closed.Do(func() {
+		close(a.stop)
+		<-a.done
+		a.mu.Lock()
+		if a.raw > 0 {
+			a.rotate()
+		}
+		a.mu.Unlock()
+		a.uploads.Wait()
+	})
+}
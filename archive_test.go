@@ -0,0 +1,58 @@
+package kinesiswriter_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	kinesiswriter "github.com/mackee/go-kinesis-writer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryUploader struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (u *memoryUploader) Upload(ctx context.Context, key string, body []byte) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.objects == nil {
+		u.objects = make(map[string][]byte)
+	}
+	u.objects[key] = body
+	return nil
+}
+
+func TestWriterArchive(t *testing.T) {
+	ctx := context.Background()
+	uploader := &memoryUploader{}
+	writer, err := kinesiswriter.New(ctx, testStreamARN,
+		kinesiswriter.WithKinesisClient(&successKinesisClient{}),
+		kinesiswriter.WithImmediateFlush(),
+		kinesiswriter.WithArchive(uploader, "archive/test", time.Hour),
+	)
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("record1\nrecord2"))
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("record3"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	require.Len(t, uploader.objects, 1)
+	for key, body := range uploader.objects {
+		assert.True(t, strings.HasPrefix(key, "archive/test/"+time.Now().UTC().Format("2006/01/02/")), key)
+		assert.True(t, strings.HasSuffix(key, "-0.gz"), key)
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		require.NoError(t, err)
+		data, err := io.ReadAll(zr)
+		require.NoError(t, err)
+		assert.Equal(t, "record1\nrecord2\nrecord3\n", string(data))
+	}
+}
@@ -0,0 +1,65 @@
+package kinesiswriter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+)
+
+// StreamARN is a parsed Kinesis stream ARN,
+// e.g. arn:aws:kinesis:us-east-1:123456789012:stream/my-stream.
+type StreamARN struct {
+	Partition  string
+	Region     string
+	AccountID  string
+	StreamName string
+}
+
+// String returns the ARN in its canonical form.
+func (a StreamARN) String() string {
+	return arn.ARN{
+		Partition: a.Partition,
+		Service:   "kinesis",
+		Region:    a.Region,
+		AccountID: a.AccountID,
+		Resource:  "stream/" + a.StreamName,
+	}.String()
+}
+
+// InvalidARNError is returned when a stream ARN is malformed.
+type InvalidARNError struct {
+	ARN    string
+	Reason string
+}
+
+func (e *InvalidARNError) Error() string {
+	return fmt.Sprintf("invalid Kinesis stream ARN %q: %s", e.ARN, e.Reason)
+}
+
+// ParseStreamARN parses a Kinesis stream ARN. It returns an *InvalidARNError for malformed input.
+func ParseStreamARN(s string) (StreamARN, error) {
+	a, err := arn.Parse(s)
+	if err != nil {
+		return StreamARN{}, &InvalidARNError{ARN: s, Reason: err.Error()}
+	}
+	if a.Service != "kinesis" {
+		return StreamARN{}, &InvalidARNError{ARN: s, Reason: fmt.Sprintf("service is %q, not kinesis", a.Service)}
+	}
+	if a.Region == "" {
+		return StreamARN{}, &InvalidARNError{ARN: s, Reason: "region is empty"}
+	}
+	if a.AccountID == "" {
+		return StreamARN{}, &InvalidARNError{ARN: s, Reason: "account ID is empty"}
+	}
+	name, ok := strings.CutPrefix(a.Resource, "stream/")
+	if !ok || name == "" || strings.Contains(name, "/") {
+		return StreamARN{}, &InvalidARNError{ARN: s, Reason: fmt.Sprintf("resource %q is not a stream", a.Resource)}
+	}
+	return StreamARN{
+		Partition:  a.Partition,
+		Region:     a.Region,
+		AccountID:  a.AccountID,
+		StreamName: name,
+	}, nil
+}
@@ -0,0 +1,48 @@
+package kinesiswriter_test
+
+import (
+	"testing"
+
+	kinesiswriter "github.com/mackee/go-kinesis-writer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStreamARN(t *testing.T) {
+	tests := []struct {
+		name    string
+		arn     string
+		expect  kinesiswriter.StreamARN
+		invalid bool
+	}{
+		{
+			name: "success",
+			arn:  "arn:aws:kinesis:ap-northeast-1:123456789012:stream/test-stream",
+			expect: kinesiswriter.StreamARN{
+				Partition:  "aws",
+				Region:     "ap-northeast-1",
+				AccountID:  "123456789012",
+				StreamName: "test-stream",
+			},
+		},
+		{name: "error: not an ARN", arn: "stream-arn", invalid: true},
+		{name: "error: other service", arn: "arn:aws:firehose:ap-northeast-1:123456789012:deliverystream/test", invalid: true},
+		{name: "error: no stream name", arn: "arn:aws:kinesis:ap-northeast-1:123456789012:stream/", invalid: true},
+		{name: "error: consumer ARN", arn: "arn:aws:kinesis:ap-northeast-1:123456789012:stream/test/consumer/c:1", invalid: true},
+		{name: "error: no region", arn: "arn:aws:kinesis::123456789012:stream/test", invalid: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := kinesiswriter.ParseStreamARN(tt.arn)
+			if tt.invalid {
+				var invalidARN *kinesiswriter.InvalidARNError
+				require.ErrorAs(t, err, &invalidARN)
+				assert.Equal(t, tt.arn, invalidARN.ARN)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expect, got)
+			assert.Equal(t, tt.arn, got.String())
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package kinesiswriter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// DeliveryReceipt records that Kinesis accepted a record.
+type DeliveryReceipt struct {
+	// RecordHash is the hex encoded SHA-256 digest of the record data.
+	RecordHash     string    `json:"recordHash"`
+	SequenceNumber string    `json:"sequenceNumber"`
+	ShardID        string    `json:"shardId"`
+	AcceptedAt     time.Time `json:"acceptedAt"`
+	// WriterID is the ID of the writer set with WithWriterID, if any.
+	WriterID string `json:"writerId,omitempty"`
+}
+
+// DeliveryAuditSink receives receipts of accepted records, e.g. to prove to auditors
+// that every message was accepted by Kinesis.
+// RecordDeliveries is called once per PutRecords call and must be safe for concurrent use.
+type DeliveryAuditSink interface {
+	RecordDeliveries(receipts []DeliveryReceipt) error
+}
+
+// JSONAuditSink is a DeliveryAuditSink writing receipts as lines of JSON.
+type JSONAuditSink struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewJSONAuditSink creates a JSONAuditSink writing to w.
+func NewJSONAuditSink(w io.Writer) *JSONAuditSink {
+	return &JSONAuditSink{enc: json.NewEncoder(w)}
+}
+
+// RecordDeliveries writes receipts to the underlying writer.
+func (s *JSONAuditSink) RecordDeliveries(receipts []DeliveryReceipt) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range receipts {
+		if err := s.enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func recordHash(record []byte) string {
+	sum := sha256.Sum256(record)
+	return hex.EncodeToString(sum[:])
+}
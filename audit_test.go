@@ -0,0 +1,99 @@
+package kinesiswriter_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	kinesiswriter "github.com/mackee/go-kinesis-writer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeliveryAuditSink(t *testing.T) {
+	ctx := context.Background()
+	var audit bytes.Buffer
+	writer, err := kinesiswriter.New(ctx, testStreamARN,
+		kinesiswriter.WithKinesisClient(&partialFailedKinesisClient{}),
+		kinesiswriter.WithImmediateFlush(),
+		kinesiswriter.WithDeliveryAuditSink(kinesiswriter.NewJSONAuditSink(&audit)),
+	)
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("record1\nrecord2"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	var hashes []string
+	dec := json.NewDecoder(&audit)
+	for dec.More() {
+		var receipt kinesiswriter.DeliveryReceipt
+		require.NoError(t, dec.Decode(&receipt))
+		assert.NotEmpty(t, receipt.SequenceNumber)
+		assert.NotEmpty(t, receipt.ShardID)
+		hashes = append(hashes, receipt.RecordHash)
+	}
+	hash := func(s string) string {
+		sum := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+	assert.Equal(t, []string{hash("record1"), hash("record2")}, hashes)
+}
+
+func TestWriterID(t *testing.T) {
+	ctx := context.Background()
+	var audit bytes.Buffer
+	var generated int
+	factory, err := kinesiswriter.NewFactory(ctx,
+		kinesiswriter.WithKinesisClient(&successKinesisClient{}),
+		kinesiswriter.WithImmediateFlush(),
+		kinesiswriter.WithDeliveryAuditSink(kinesiswriter.NewJSONAuditSink(&audit)),
+		kinesiswriter.WithWriterIDGenerator(func() string {
+			generated++
+			return fmt.Sprintf("producer-%d", generated)
+		}),
+	)
+	require.NoError(t, err)
+
+	client := &successKinesisClient{}
+	for _, id := range []string{"producer-1", "producer-2"} {
+		writer, err := factory.New(ctx, testStreamARN,
+			kinesiswriter.WithKinesisClient(client),
+			kinesiswriter.WithEncodingAnnouncement(time.Hour),
+		)
+		require.NoError(t, err)
+		assert.Equal(t, id, writer.ID())
+		_, err = writer.Write([]byte("record1"))
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+		assert.Equal(t, id, writer.Stats().WriterID)
+	}
+
+	var ids []string
+	dec := json.NewDecoder(&audit)
+	for dec.More() {
+		var receipt kinesiswriter.DeliveryReceipt
+		require.NoError(t, dec.Decode(&receipt))
+		ids = append(ids, receipt.WriterID)
+	}
+	assert.Equal(t, []string{"producer-1", "producer-1", "producer-2", "producer-2"}, ids)
+	var announced []string
+	for _, input := range client.Inputs() {
+		for _, entry := range input.Records {
+			if a, ok := kinesiswriter.ParseEncodingAnnouncement(entry.Data); ok {
+				announced = append(announced, a.WriterID)
+			}
+		}
+	}
+	assert.Equal(t, []string{"producer-1", "producer-2"}, announced)
+
+	writer, err := factory.New(ctx, testStreamARN, kinesiswriter.WithWriterID("fixed"))
+	require.NoError(t, err)
+	assert.Equal(t, "fixed", writer.ID())
+	require.NoError(t, writer.Close())
+	assert.Equal(t, 2, generated)
+}
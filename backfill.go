@@ -0,0 +1,252 @@
+package kinesiswriter
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+const defaultBackfillConcurrency = 4
+
+// BackfillProgress reports how far a backfill has got.
+type BackfillProgress struct {
+	// Records is the number of delivered records.
+	Records int64
+	// Bytes is the data size of the delivered records.
+	Bytes int64
+	// Offset is the input offset up to which every record has been delivered.
+	// Pass it to WithBackfillResumeOffset to resume an interrupted backfill.
+	Offset int64
+}
+
+type backfillConfig struct {
+	concurrency  int
+	resumeOffset int64
+	progress     func(BackfillProgress)
+}
+
+// BackfillOption is a configuration option for Writer.Backfill.
+type BackfillOption func(*backfillConfig)
+
+// WithBackfillConcurrency sets the number of batches sent at the same time.
+// The default is the number of open shards when the client implements KinesisShardLister,
+// since a shard accepts up to 1,000 records per second, and 4 otherwise.
+func WithBackfillConcurrency(n int) BackfillOption {
+	return func(c *backfillConfig) {
+		c.concurrency = n
+	}
+}
+
+// WithBackfillResumeOffset skips the first offset bytes of the input,
+// which are expected to be delivered by a previous backfill.
+func WithBackfillResumeOffset(offset int64) BackfillOption {
+	return func(c *backfillConfig) {
+		c.resumeOffset = offset
+	}
+}
+
+// WithBackfillProgress sets a callback invoked whenever the delivered offset advances.
+func WithBackfillProgress(fn func(BackfillProgress)) BackfillOption {
+	return func(c *backfillConfig) {
+		c.progress = fn
+	}
+}
+
+type backfillBatch struct {
+	seq     int
+	records [][]byte
+	size    int
+	end     int64
+}
+
+// Backfill reads records from r until EOF and sends them in batches as large as Kinesis allows,
+// concurrently, bypassing the buffer. Records are transformed, validated and limited like
+// those of Write, and copied to the tee and the archive. It is meant for bulk imports rather
+// than for streaming. It stops with ErrWriterClosed once the writer is shut down or closed.
+// It returns the progress made so far, which can be used to resume after an error.
+func (w *Writer) Backfill(ctx context.Context, r io.Reader, opts ...BackfillOption) (BackfillProgress, error) {
+	if w.stopped() {
+		return BackfillProgress{}, ErrWriterClosed
+	}
+	conf := &backfillConfig{}
+	for _, opt := range opts {
+		opt(conf)
+	}
+	if conf.concurrency <= 0 {
+		conf.concurrency = w.backfillConcurrency(ctx)
+	}
+	if conf.resumeOffset > 0 {
+		if _, err := io.CopyN(io.Discard, r, conf.resumeOffset); err != nil {
+			return BackfillProgress{Offset: conf.resumeOffset}, fmt.Errorf("failed to skip to resume offset: %w", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	tracker := &backfillTracker{
+		progress: BackfillProgress{Offset: conf.resumeOffset},
+		done:     make(map[int]backfillBatch),
+		callback: conf.progress,
+	}
+	batches := make(chan backfillBatch)
+	var wg sync.WaitGroup
+	for range conf.concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for b := range batches {
+				if ctx.Err() != nil {
+					return
+				}
+				if err := w.sendBackfill(b.records); err != nil {
+					tracker.fail(err)
+					cancel()
+					return
+				}
+				tracker.complete(b)
+			}
+		}()
+	}
+
+	readErr := w.readBatches(ctx, r, conf.resumeOffset, batches)
+	close(batches)
+	wg.Wait()
+
+	progress, err := tracker.result()
+	if err != nil {
+		return progress, err
+	}
+	return progress, readErr
+}
+
+// sendBackfill delivers a batch of backfilled records, copying them to the tee and
+// the archive as Write does.
+func (w *Writer) sendBackfill(records [][]byte) error {
+	w.closeMu.RLock()
+	defer w.closeMu.RUnlock()
+	if w.stopped() {
+		return ErrWriterClosed
+	}
+	if w.config.tee != nil {
+		w.writeTee(records)
+	}
+	if w.archiver != nil {
+		w.archiver.add(records)
+	}
+	w.flusher.health.enqueued(len(records), recordsSize(records))
+	if err := w.flusher.Flush(records); err != nil {
+		return fmt.Errorf("failed to backfill records: %w", err)
+	}
+	return nil
+}
+
+// readBatches splits r into records and sends batches of up to maxRequestRecords records
+// and maxRequestSize bytes to batches.
+func (w *Writer) readBatches(ctx context.Context, r io.Reader, offset int64, batches chan<- backfillBatch) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxOversizeRecordSize)
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := w.config.splitFunc(data, atEOF)
+		offset += int64(advance)
+		return advance, token, err
+	})
+
+	var seq int
+	b := backfillBatch{end: offset}
+	send := func() error {
+		b.seq = seq
+		select {
+		case batches <- b:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		seq++
+		// the next batch may hold only part of the records of a line, so it does not
+		// advance the offset unless it completes one.
+		b = backfillBatch{end: b.end}
+		return nil
+	}
+	for scanner.Scan() {
+		for _, record := range w.prepare([][]byte{bytes.Clone(scanner.Bytes())}) {
+			if len(b.records) == maxRequestRecords || b.size+len(record)+maxPartitionKeySize > maxRequestSize {
+				if err := send(); err != nil {
+					return err
+				}
+			}
+			b.records = append(b.records, record)
+			b.size += len(record) + maxPartitionKeySize
+		}
+		b.end = offset
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read records: %w", err)
+	}
+	if len(b.records) > 0 {
+		return send()
+	}
+	return nil
+}
+
+func (w *Writer) backfillConcurrency(ctx context.Context) int {
+	lister, ok := w.config.client.(KinesisShardLister)
+	if !ok {
+		return defaultBackfillConcurrency
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	shards, err := listOpenShards(ctx, lister, w.streamARN.String())
+	if err != nil || len(shards) == 0 {
+		return defaultBackfillConcurrency
+	}
+	return len(shards)
+}
+
+// backfillTracker advances the delivered offset as batches complete, in input order.
+type backfillTracker struct {
+	mu       sync.Mutex
+	progress BackfillProgress
+	next     int
+	done     map[int]backfillBatch
+	err      error
+	callback func(BackfillProgress)
+}
+
+func (t *backfillTracker) complete(b backfillBatch) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.done[b.seq] = b
+	advanced := false
+	for {
+		d, ok := t.done[t.next]
+		if !ok {
+			break
+		}
+		delete(t.done, t.next)
+		t.next++
+		t.progress.Records += int64(len(d.records))
+		t.progress.Bytes += int64(recordsSize(d.records))
+		t.progress.Offset = d.end
+		advanced = true
+	}
+	if advanced && t.callback != nil {
+		t.callback(t.progress)
+	}
+}
+
+func (t *backfillTracker) fail(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.err == nil {
+		t.err = err
+	}
+}
+
+func (t *backfillTracker) result() (BackfillProgress, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.progress, t.err
+}
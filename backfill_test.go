@@ -0,0 +1,170 @@
+package kinesiswriter_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/kinesis"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis/types"
+	"github.com/aws/smithy-go"
+	kinesiswriter "github.com/mackee/go-kinesis-writer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterBackfill(t *testing.T) {
+	ctx := context.Background()
+	var input strings.Builder
+	for i := range 1200 {
+		fmt.Fprintf(&input, "record%04d\n", i)
+	}
+
+	var mu sync.Mutex
+	var batchSizes []int
+	writer, err := kinesiswriter.New(ctx, testStreamARN,
+		kinesiswriter.WithKinesisClient(&successKinesisClient{}),
+		kinesiswriter.WithPutRecordsMiddleware(func(next kinesiswriter.PutRecordsFunc) kinesiswriter.PutRecordsFunc {
+			return func(ctx context.Context, params *kinesis.PutRecordsInput, optFns ...func(*kinesis.Options)) (*kinesis.PutRecordsOutput, error) {
+				mu.Lock()
+				batchSizes = append(batchSizes, len(params.Records))
+				mu.Unlock()
+				return &kinesis.PutRecordsOutput{
+					Records: make([]types.PutRecordsResultEntry, len(params.Records)),
+				}, nil
+			}
+		}),
+	)
+	require.NoError(t, err)
+	defer writer.Close()
+
+	var offsets []int64
+	progress, err := writer.Backfill(ctx, strings.NewReader(input.String()),
+		kinesiswriter.WithBackfillConcurrency(2),
+		kinesiswriter.WithBackfillProgress(func(p kinesiswriter.BackfillProgress) {
+			offsets = append(offsets, p.Offset)
+		}),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, kinesiswriter.BackfillProgress{
+		Records: 1200,
+		Bytes:   1200 * 10,
+		Offset:  int64(input.Len()),
+	}, progress)
+	assert.ElementsMatch(t, []int{500, 500, 200}, batchSizes)
+	assert.IsIncreasing(t, offsets)
+	assert.Equal(t, int64(input.Len()), offsets[len(offsets)-1])
+
+	batchSizes = nil
+	progress, err = writer.Backfill(ctx, strings.NewReader(input.String()),
+		kinesiswriter.WithBackfillResumeOffset(1000*11),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, int64(200), progress.Records)
+	assert.Equal(t, int64(input.Len()), progress.Offset)
+	assert.Equal(t, []int{200}, batchSizes)
+}
+
+func TestWriterBackfillPrepare(t *testing.T) {
+	client := &successKinesisClient{}
+	var rejected []string
+	writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithTransform(func(record []byte) ([]byte, error) {
+			return bytes.ToUpper(record), nil
+		}),
+		kinesiswriter.WithRecordValidator(func(record []byte) error {
+			if string(record) == "INVALID" {
+				return errors.New("invalid")
+			}
+			return nil
+		}),
+		kinesiswriter.WithRejectHandler(func(err error, record []byte) {
+			rejected = append(rejected, string(record))
+		}),
+	)
+	require.NoError(t, err)
+	defer writer.Close()
+
+	progress, err := writer.Backfill(context.Background(), strings.NewReader("a\n\ninvalid\nb\n"),
+		kinesiswriter.WithBackfillConcurrency(1),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), progress.Records)
+	require.Len(t, client.Inputs(), 1)
+	var records []string
+	for _, entry := range client.Inputs()[0].Records {
+		records = append(records, string(entry.Data))
+	}
+	assert.Equal(t, []string{"A", "B"}, records)
+	assert.Equal(t, []string{"INVALID"}, rejected)
+}
+
+func TestWriterBackfillError(t *testing.T) {
+	var input strings.Builder
+	for i := range 1200 {
+		fmt.Fprintf(&input, "record%04d\n", i)
+	}
+	client := &errorKinesisClient{err: &smithy.GenericAPIError{Code: "AccessDeniedException"}}
+	writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithBufferErrorHandler(func(error, [][]byte) {}),
+		kinesiswriter.WithNoRetry(),
+	)
+	require.NoError(t, err)
+	defer writer.Close()
+
+	progress, err := writer.Backfill(context.Background(), strings.NewReader(input.String()),
+		kinesiswriter.WithBackfillConcurrency(1),
+	)
+	require.Error(t, err)
+	assert.Zero(t, progress.Offset)
+	assert.Len(t, client.Inputs(), 1, "backfill stops at the first failed batch")
+}
+
+func TestWriterBackfillTee(t *testing.T) {
+	var tee bytes.Buffer
+	writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+		kinesiswriter.WithKinesisClient(&successKinesisClient{}),
+		kinesiswriter.WithTee(&tee),
+	)
+	require.NoError(t, err)
+	_, err = writer.Backfill(context.Background(), strings.NewReader("record1\nrecord2\n"),
+		kinesiswriter.WithBackfillConcurrency(1),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "record1\nrecord2\n", tee.String())
+	require.NoError(t, writer.Close())
+}
+
+func TestWriterBackfillClosed(t *testing.T) {
+	client := &successKinesisClient{}
+	writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+		kinesiswriter.WithKinesisClient(client),
+	)
+	require.NoError(t, err)
+
+	var input strings.Builder
+	for i := range 1200 {
+		fmt.Fprintf(&input, "record%04d\n", i)
+	}
+	progress, err := writer.Backfill(context.Background(), strings.NewReader(input.String()),
+		kinesiswriter.WithBackfillConcurrency(1),
+		kinesiswriter.WithBackfillProgress(func(progress kinesiswriter.BackfillProgress) {
+			if progress.Records == 500 {
+				require.NoError(t, writer.Shutdown())
+			}
+		}),
+	)
+	assert.ErrorIs(t, err, kinesiswriter.ErrWriterClosed)
+	assert.Equal(t, int64(500), progress.Records)
+	assert.Len(t, client.Inputs(), 1)
+
+	_, err = writer.Backfill(context.Background(), strings.NewReader("record\n"))
+	assert.ErrorIs(t, err, kinesiswriter.ErrWriterClosed)
+	require.NoError(t, writer.Close())
+}
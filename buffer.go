@@ -0,0 +1,180 @@
+package kinesiswriter
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	buffer "github.com/woorui/async-buffer"
+)
+
+// Record is a record waiting in a Buffer.
+type Record struct {
+	Data []byte
+	// EnqueuedAt is when the record was written to the Writer.
+	EnqueuedAt time.Time
+	// PartitionKey and ExplicitHashKey are set for records written with WriteEntries.
+	// Other records get them from the configured partitioning when they are sent.
+	PartitionKey    string
+	ExplicitHashKey *string
+
+	// sum is the hash of Data taken by WithMutationGuard when guarded is set.
+	sum     uint64
+	guarded bool
+}
+
+// Buffer holds records written to a Writer until they are flushed.
+// A Writer has a Buffer for each priority lane.
+type Buffer interface {
+	// Write adds records to the buffer and returns the number of records added.
+	Write(records ...Record) (int, error)
+	// Flush requests the buffered records to be flushed.
+	Flush() error
+	// Close flushes the remaining records and releases the buffer.
+	Close() error
+	// Len returns the number of records waiting in the buffer.
+	Len() int
+}
+
+// NewBufferFunc creates a Buffer delivering its records with flush. flush sends
+// a batch with retries, in several PutRecords calls if it is over 500 records or 5MiB.
+// Errors of flush are for the Buffer to handle.
+type NewBufferFunc func(flush func(records []Record) error) Buffer
+
+// asyncBuffer is the default Buffer based on github.com/woorui/async-buffer.
+type asyncBuffer struct {
+	buf *buffer.Buffer[Record]
+	len atomic.Int64
+	// mu serializes Flush and Close, as flush requests are not consumed after Close.
+	mu     sync.Mutex
+	closed bool
+}
+
+func newAsyncBuffer(flush func(records []Record) error, conf *bufferConfig, flushTimeout time.Duration) *asyncBuffer {
+	b := &asyncBuffer{}
+	b.buf = buffer.New[Record](buffer.FlushFunc[Record](func(records []Record) error {
+		b.len.Add(-int64(len(records)))
+		return flush(records)
+	}), buffer.Option[Record]{
+		Threshold:     conf.recordWindow,
+		WriteTimeout:  conf.writeTimeout,
+		FlushTimeout:  flushTimeout,
+		FlushInterval: conf.flushInterval,
+		ErrHandler: func(err error, records []Record) {
+			conf.errorHandler(err, recordsData(records))
+		},
+	})
+	return b
+}
+
+func (b *asyncBuffer) Write(records ...Record) (int, error) {
+	n, err := b.buf.Write(records...)
+	b.len.Add(int64(n))
+	if errors.Is(err, buffer.ErrClosed) {
+		return n, ErrWriterClosed
+	}
+	return n, err
+}
+
+func (b *asyncBuffer) Flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return ErrWriterClosed
+	}
+	b.buf.Flush()
+	return nil
+}
+
+func (b *asyncBuffer) Close() error {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+	err := b.buf.Close()
+	if errors.Is(err, buffer.ErrClosed) {
+		return ErrWriterClosed
+	}
+	return err
+}
+
+func (b *asyncBuffer) Len() int {
+	return int(b.len.Load())
+}
+
+// manualBuffer holds records until Flush or Close, which flush them synchronously
+// in batches of the record window. It has no timers nor goroutines.
+type manualBuffer struct {
+	flush  func(records []Record) error
+	window int
+
+	mu      sync.Mutex
+	records []Record
+	closed  bool
+}
+
+func (b *manualBuffer) Write(records ...Record) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return 0, ErrWriterClosed
+	}
+	b.records = append(b.records, records...)
+	return len(records), nil
+}
+
+// Flush flushes the buffered records and returns the errors of the batches.
+func (b *manualBuffer) Flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var errs []error
+	for len(b.records) > 0 {
+		n := min(len(b.records), b.window)
+		if err := b.flush(b.records[:n:n]); err != nil {
+			errs = append(errs, err)
+		}
+		b.records = b.records[n:]
+	}
+	b.records = nil
+	return errors.Join(errs...)
+}
+
+func (b *manualBuffer) Close() error {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+	return b.Flush()
+}
+
+func (b *manualBuffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.records)
+}
+
+// bufferFlusher flushes the records of a buffer.
+type bufferFlusher struct {
+	flusher *flusher
+}
+
+func (b bufferFlusher) Flush(records []Record) error {
+	return b.flusher.flushRecords(records)
+}
+
+// newRecords wraps data in records enqueued now.
+func newRecords(data [][]byte) []Record {
+	now := time.Now()
+	records := make([]Record, len(data))
+	for i, d := range data {
+		records[i] = Record{Data: d, EnqueuedAt: now}
+	}
+	return records
+}
+
+func recordsData(records []Record) [][]byte {
+	data := make([][]byte, len(records))
+	for i, r := range records {
+		data[i] = r.Data
+	}
+	return data
+}
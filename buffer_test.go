@@ -0,0 +1,154 @@
+package kinesiswriter_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	kinesiswriter "github.com/mackee/go-kinesis-writer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sliceBuffer holds records until Flush or Close.
+type sliceBuffer struct {
+	mu      sync.Mutex
+	records []kinesiswriter.Record
+	flush   func([]kinesiswriter.Record) error
+	closed  bool
+}
+
+func (b *sliceBuffer) Write(records ...kinesiswriter.Record) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.records = append(b.records, records...)
+	return len(records), nil
+}
+
+func (b *sliceBuffer) Flush() error {
+	b.mu.Lock()
+	records := b.records
+	b.records = nil
+	b.mu.Unlock()
+	if len(records) == 0 {
+		return nil
+	}
+	return b.flush(records)
+}
+
+func (b *sliceBuffer) Close() error {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+	return b.Flush()
+}
+
+func (b *sliceBuffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.records)
+}
+
+func TestWriterWithBuffer(t *testing.T) {
+	client := &successKinesisClient{}
+	var buffers []*sliceBuffer
+	writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithBuffer(func(flush func([]kinesiswriter.Record) error) kinesiswriter.Buffer {
+			b := &sliceBuffer{flush: flush}
+			buffers = append(buffers, b)
+			return b
+		}),
+	)
+	require.NoError(t, err)
+	assert.Len(t, buffers, 2, "a buffer for each priority lane")
+
+	_, err = writer.Write([]byte("record1\nrecord2\n"))
+	require.NoError(t, err)
+	assert.Equal(t, 2, writer.Len())
+	assert.Empty(t, client.Inputs())
+
+	require.NoError(t, writer.Sync())
+	assert.Equal(t, 0, writer.Len())
+	require.Len(t, client.Inputs(), 1)
+	assert.Len(t, client.Inputs()[0].Records, 2)
+	require.NoError(t, writer.Close())
+}
+
+func TestWriterLen(t *testing.T) {
+	writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+		kinesiswriter.WithKinesisClient(&successKinesisClient{}),
+	)
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("record1\nrecord2\n"))
+	require.NoError(t, err)
+	assert.Equal(t, 2, writer.Len())
+	require.NoError(t, writer.Close())
+	assert.Equal(t, 0, writer.Len())
+}
+
+func TestWriterManualFlush(t *testing.T) {
+	client := &successKinesisClient{}
+	writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithBufferRecordWindow(2),
+		kinesiswriter.WithManualFlush(),
+	)
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("record1\nrecord2\nrecord3\n"))
+	require.NoError(t, err)
+	assert.Empty(t, client.Inputs())
+	assert.Equal(t, 3, writer.Len())
+
+	require.NoError(t, writer.Sync())
+	require.Len(t, client.Inputs(), 2)
+	assert.Len(t, client.Inputs()[0].Records, 2)
+	assert.Len(t, client.Inputs()[1].Records, 1)
+	assert.Zero(t, writer.Len())
+
+	_, err = writer.Write([]byte("record4\n"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	assert.Len(t, client.Inputs(), 3)
+}
+
+func TestWriterManualFlushError(t *testing.T) {
+	writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+		kinesiswriter.WithKinesisClient(&errorKinesisClient{err: errors.New("unavailable")}),
+		kinesiswriter.WithManualFlush(),
+	)
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("record1\n"))
+	require.NoError(t, err)
+	var flushErr *kinesiswriter.FlushError
+	assert.ErrorAs(t, writer.Sync(), &flushErr)
+	require.NoError(t, writer.Close())
+}
+
+func TestWriterWriteDeadline(t *testing.T) {
+	client := &blockingKinesisClient{blocked: testStreamARN, release: make(chan struct{})}
+	writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithBufferRecordWindow(2),
+		kinesiswriter.WithBufferFlushInterval(time.Hour),
+		kinesiswriter.WithBufferErrorHandler(func(error, [][]byte) {}),
+		kinesiswriter.WithWriteDeadline(100*time.Millisecond),
+	)
+	require.NoError(t, err)
+
+	start := time.Now()
+	_, err = writer.Write([]byte(strings.Repeat("record\n", 20)))
+	assert.Less(t, time.Since(start), time.Second)
+	var timeoutErr *kinesiswriter.WriteTimeoutError
+	require.ErrorAs(t, err, &timeoutErr)
+	assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	assert.Positive(t, timeoutErr.NotAcceptedRecords)
+	assert.Equal(t, timeoutErr.NotAcceptedRecords*len("record"), timeoutErr.NotAcceptedBytes)
+
+	close(client.release)
+	require.NoError(t, writer.Close())
+}
@@ -0,0 +1,68 @@
+package kinesiswriter
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis/types"
+)
+
+// canaryPrefix starts every canary record.
+const canaryPrefix = `{"kinesiswriter_canary":`
+
+// CanaryResult reports the acceptance of a canary record by Kinesis.
+type CanaryResult struct {
+	// ID identifies the canary record within the writer.
+	ID string
+	// SentAt is when the canary record was written.
+	SentAt time.Time
+	// AcceptedAt is when Kinesis accepted it.
+	AcceptedAt time.Time
+	// Latency is the time from SentAt to AcceptedAt, through the buffer and retries.
+	Latency        time.Duration
+	ShardID        string
+	SequenceNumber string
+}
+
+type canaryRecord struct {
+	ID     string    `json:"kinesiswriter_canary"`
+	SentAt time.Time `json:"sent_at"`
+}
+
+// canary writes canary records and reports their acceptance.
+type canary struct {
+	callback func(CanaryResult)
+	seq      atomic.Int64
+}
+
+// record returns a new canary record.
+func (c *canary) record() []byte {
+	record, _ := json.Marshal(canaryRecord{
+		ID:     strconv.FormatInt(c.seq.Add(1), 10),
+		SentAt: time.Now(),
+	})
+	return record
+}
+
+// accepted reports record if it is a canary record accepted as entry.
+func (c *canary) accepted(record []byte, entry types.PutRecordsResultEntry, at time.Time) {
+	if !bytes.HasPrefix(record, []byte(canaryPrefix)) {
+		return
+	}
+	var cr canaryRecord
+	if err := json.Unmarshal(record, &cr); err != nil {
+		return
+	}
+	c.callback(CanaryResult{
+		ID:             cr.ID,
+		SentAt:         cr.SentAt,
+		AcceptedAt:     at,
+		Latency:        at.Sub(cr.SentAt),
+		ShardID:        aws.ToString(entry.ShardId),
+		SequenceNumber: aws.ToString(entry.SequenceNumber),
+	})
+}
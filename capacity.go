@@ -0,0 +1,87 @@
+package kinesiswriter
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"time"
+)
+
+// Write limits of a single provisioned shard.
+const (
+	shardRecordsPerSecond = 1000
+	shardBytesPerSecond   = 1 << 20
+)
+
+// CapacityPlan is a shard count recommendation computed from observed throughput.
+type CapacityPlan struct {
+	// RecordsPerSecond is the observed record rate.
+	RecordsPerSecond float64
+	// BytesPerSecond is the observed data rate.
+	BytesPerSecond float64
+	// PeakFactor is the multiplier applied to the observed rates to cover peaks.
+	PeakFactor float64
+	// RequiredShards is the number of shards needed to absorb the peak rates.
+	RequiredShards int
+	// CurrentShards is the number of open shards of the stream, or 0 if unknown.
+	CurrentShards int
+}
+
+// PlanCapacity computes the shards needed for the throughput between two Stats snapshots
+// taken elapsed apart, multiplied by peakFactor. A peakFactor below 1 is treated as 1.
+func PlanCapacity(before, after Stats, elapsed time.Duration, peakFactor float64) CapacityPlan {
+	peakFactor = max(peakFactor, 1)
+	plan := CapacityPlan{PeakFactor: peakFactor}
+	if elapsed <= 0 {
+		return plan
+	}
+	seconds := elapsed.Seconds()
+	plan.RecordsPerSecond = float64(after.RecordsSent-before.RecordsSent) / seconds
+	plan.BytesPerSecond = float64(bytesSent(after)-bytesSent(before)) / seconds
+	plan.RequiredShards = max(1, int(math.Ceil(max(
+		plan.RecordsPerSecond*peakFactor/shardRecordsPerSecond,
+		plan.BytesPerSecond*peakFactor/shardBytesPerSecond,
+	))))
+	return plan
+}
+
+func bytesSent(s Stats) int64 {
+	var n int64
+	for _, shard := range s.Shards {
+		n += shard.BytesSent
+	}
+	return n
+}
+
+// Recommendation describes how the shard count should change.
+func (p CapacityPlan) Recommendation() string {
+	need := fmt.Sprintf("%d shards needed for %.0f records/s and %.0f bytes/s at %.1fx peak",
+		p.RequiredShards, p.RecordsPerSecond, p.BytesPerSecond, p.PeakFactor)
+	switch {
+	case p.CurrentShards == 0:
+		return need
+	case p.RequiredShards > p.CurrentShards:
+		return fmt.Sprintf("%s, add %d to the current %d", need, p.RequiredShards-p.CurrentShards, p.CurrentShards)
+	case p.RequiredShards < p.CurrentShards:
+		return fmt.Sprintf("%s, %d of the current %d can be removed", need, p.CurrentShards-p.RequiredShards, p.CurrentShards)
+	default:
+		return fmt.Sprintf("%s, the current %d fit", need, p.CurrentShards)
+	}
+}
+
+// PlanCapacity plans the shards for the throughput since before, a Stats snapshot
+// taken elapsed ago, and logs the recommendation.
+// CurrentShards is filled in when the client implements KinesisShardLister.
+func (w *Writer) PlanCapacity(ctx context.Context, before Stats, elapsed time.Duration, peakFactor float64) (CapacityPlan, error) {
+	plan := PlanCapacity(before, w.Stats(), elapsed, peakFactor)
+	if lister, ok := w.config.client.(KinesisShardLister); ok {
+		shards, err := listOpenShards(ctx, lister, w.streamARN.String())
+		if err != nil {
+			return plan, fmt.Errorf("failed to list shards: %w", err)
+		}
+		plan.CurrentShards = len(shards)
+	}
+	log.Printf("shard capacity: %s", plan.Recommendation())
+	return plan, nil
+}
@@ -0,0 +1,81 @@
+package kinesiswriter_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis/types"
+	kinesiswriter "github.com/mackee/go-kinesis-writer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanCapacity(t *testing.T) {
+	before := kinesiswriter.Stats{
+		RecordsSent: 1000,
+		Shards:      map[string]kinesiswriter.ShardStats{"shardId-0": {BytesSent: 1 << 20}},
+	}
+	tests := []struct {
+		name       string
+		after      kinesiswriter.Stats
+		peakFactor float64
+		want       int
+	}{
+		{
+			name:  "records bound",
+			after: kinesiswriter.Stats{RecordsSent: 1000 + 2500*10, Shards: before.Shards},
+			want:  3,
+		},
+		{
+			name: "bytes bound",
+			after: kinesiswriter.Stats{
+				RecordsSent: 1010,
+				Shards:      map[string]kinesiswriter.ShardStats{"shardId-0": {BytesSent: 1<<20 + 15<<20}, "shardId-1": {BytesSent: 10 << 20}},
+			},
+			want: 3,
+		},
+		{
+			name:       "peak factor",
+			after:      kinesiswriter.Stats{RecordsSent: 1000 + 2500*10, Shards: before.Shards},
+			peakFactor: 2,
+			want:       5,
+		},
+		{
+			name:  "idle",
+			after: before,
+			want:  1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := kinesiswriter.PlanCapacity(before, tt.after, 10*time.Second, tt.peakFactor)
+			assert.Equal(t, tt.want, plan.RequiredShards)
+		})
+	}
+}
+
+func TestWriterPlanCapacity(t *testing.T) {
+	client := &shardListerKinesisClient{
+		shards: []types.Shard{
+			{ShardId: aws.String("shardId-0")},
+			{ShardId: aws.String("shardId-1")},
+		},
+	}
+	writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithImmediateFlush(),
+	)
+	require.NoError(t, err)
+	defer writer.Close()
+	_, err = writer.Write([]byte("record1\nrecord2\n"))
+	require.NoError(t, err)
+
+	plan, err := writer.PlanCapacity(context.Background(), kinesiswriter.Stats{}, time.Second, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 2.0, plan.RecordsPerSecond)
+	assert.Equal(t, 1, plan.RequiredShards)
+	assert.Equal(t, 2, plan.CurrentShards)
+	assert.Equal(t, "1 shards needed for 2 records/s and 14 bytes/s at 1.0x peak, 1 of the current 2 can be removed", plan.Recommendation())
+}
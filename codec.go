@@ -0,0 +1,69 @@
+package kinesiswriter
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// Codec encodes values into records for WriteValue.
+type Codec interface {
+	Encode(v any) ([]byte, error)
+}
+
+// CodecFunc adapts a function to a Codec.
+type CodecFunc func(v any) ([]byte, error)
+
+func (f CodecFunc) Encode(v any) ([]byte, error) {
+	return f(v)
+}
+
+// JSONCodec encodes values with encoding/json. It is the default codec.
+var JSONCodec Codec = CodecFunc(json.Marshal)
+
+// GobCodec encodes values with encoding/gob. Every record carries its own type information,
+// so that records can be decoded independently.
+var GobCodec Codec = CodecFunc(func(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+})
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[string]Codec{
+		"json": JSONCodec,
+		"gob":  GobCodec,
+	}
+)
+
+// RegisterCodec makes codec available by name to LookupCodec, for codecs chosen by
+// configuration. "json" and "gob" are registered. Registering a name again replaces the codec.
+func RegisterCodec(name string, codec Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	codecs[name] = codec
+}
+
+// LookupCodec returns the codec registered as name.
+func LookupCodec(name string) (Codec, bool) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	codec, ok := codecs[name]
+	return codec, ok
+}
+
+// WriteValue writes v encoded by the codec set with WithCodec as a single record.
+// The record goes through transforms and validation like the records of Write.
+func (w *Writer) WriteValue(v any) error {
+	data, err := w.config.codec.Encode(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode record: %w", err)
+	}
+	// the codec may reuse its buffer for the next record.
+	return w.writeRecords([][]byte{bytes.Clone(data)}, w.config.priorityFunc)
+}
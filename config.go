@@ -4,7 +4,9 @@ import (
 	"bufio"
 	"context"
 	"fmt"
-	"os"
+	"io"
+	"log/slog"
+	"regexp"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/kinesis"
@@ -15,23 +17,123 @@ const (
 	defaultBufferWriteTimeout  = 5 * time.Second
 	defaultBufferFlushTimeout  = 30 * time.Second
 	defaultBufferFlushInterval = 30 * time.Second
-)
+	defaultFailureWindow       = 5 * time.Minute
 
-func defaultBufferErrorHandler(err error, elements [][]byte) {
-	fmt.Fprintf(os.Stderr, "async-buffer: error %s", err)
-	for i, elem := range elements {
-		fmt.Fprintf(os.Stderr, "failed to write logs [%d]=%s", i, string(elem))
-	}
-}
+	// maxRecordSize is the maximum size of a Kinesis record data blob.
+	maxRecordSize = 1024 * 1024
+	// maxRequestRecords is the maximum number of records of a PutRecords request.
+	maxRequestRecords = 500
+	// maxRequestSize is the maximum size of a PutRecords request, data and partition keys included.
+	maxRequestSize = 5 * 1024 * 1024
+	// maxPartitionKeySize is the maximum size of a partition key.
+	maxPartitionKeySize = 256
+	// maxOversizeRecordSize is the size of the largest record read by ReadFrom and Backfill.
+	// Records over maxRecordSize up to it are handled by the oversize policy.
+	maxOversizeRecordSize = 64 * maxRecordSize
+)
 
 type KinesisClient interface {
 	PutRecords(ctx context.Context, params *kinesis.PutRecordsInput, optFns ...func(*kinesis.Options)) (*kinesis.PutRecordsOutput, error)
 }
 
+// PutRecordsFunc is the signature of KinesisClient.PutRecords.
+type PutRecordsFunc func(ctx context.Context, params *kinesis.PutRecordsInput, optFns ...func(*kinesis.Options)) (*kinesis.PutRecordsOutput, error)
+
+// PutRecordsMiddleware wraps a PutRecordsFunc to inspect or mutate requests and responses.
+type PutRecordsMiddleware func(next PutRecordsFunc) PutRecordsFunc
+
 type writerConfig struct {
-	splitFunc    bufio.SplitFunc
-	bufferConfig *bufferConfig
-	client       KinesisClient
+	splitFunc      bufio.SplitFunc
+	bufferConfig   *bufferConfig
+	client         KinesisClient
+	immediateFlush bool
+	resultHook     PutRecordsResultHook
+	flushHook      func(FlushResult)
+	newBuffer      NewBufferFunc
+	// manager schedules the flushes of the writer when it is created by a Manager.
+	manager            *Manager
+	middlewares        []PutRecordsMiddleware
+	failureWindow      time.Duration
+	slowFlush          *slowFlushConfig
+	flushDeadline      time.Duration
+	putRecordsTimeout  time.Duration
+	retryBackoffs      map[string]RetryBackoff
+	retrier            Retrier
+	adaptiveRetry      bool
+	closePolicy        *ClosePolicy
+	statsReport        *statsReportConfig
+	memoryShedder      *memoryShedder
+	permanentErrors    map[string]struct{}
+	maxInFlight        int
+	hotShard           *hotShardConfig
+	scaleSignal        *scaleSignalConfig
+	streamARN          string
+	tenantKey          func(record []byte) string
+	streamKey          func(record []byte) string
+	fatalCh            chan<- error
+	codec              Codec
+	manifest           bool
+	writeDeadline      time.Duration
+	warmup             bool
+	errorOutput        io.Writer
+	errorFormat        ErrorFormat
+	errorLogger        *slog.Logger
+	mutationGuard      RejectHandler
+	codecName          string
+	codecNameErr       error
+	profileErr         error
+	announce           bool
+	announceInterval   time.Duration
+	writerID           string
+	writerIDGenerator  func() string
+	manifestStream     string
+	streams            map[string]string
+	tenantLimiter      *tenantLimiter
+	advisorSample      int
+	autoScaleMaxShards int
+	roundRobinHashKey  bool
+	partitionKeys      Partitioner
+	tee                io.Writer
+	requestLogger      *slog.Logger
+	preserveOrder      bool
+	auditSink          DeliveryAuditSink
+	latency            *latencyTracker
+	priorityFunc       func(record []byte) Priority
+	archive            *archiveConfig
+	validators         []RecordValidator
+	jsonSchema         string
+	rejectHandler      RejectHandler
+	transforms         []Transform
+	allowEmptyRecords  bool
+	holdPartialLine    bool
+	oversizePolicy     OversizePolicy
+	redactor           Redactor
+	heartbeat          *heartbeatConfig
+	canary             *canary
+	canaryInterval     time.Duration
+	// scanLines is set while splitFunc is the default bufio.ScanLines.
+	scanLines bool
+}
+
+type archiveConfig struct {
+	uploader ArchiveUploader
+	prefix   string
+	interval time.Duration
+}
+
+type slowFlushConfig struct {
+	threshold time.Duration
+	callback  func(elapsed time.Duration, records int)
+}
+
+type statsReportConfig struct {
+	interval time.Duration
+	report   func(Stats)
+}
+
+type heartbeatConfig struct {
+	interval time.Duration
+	payload  func() []byte
 }
 
 type bufferConfig struct {
@@ -42,6 +144,10 @@ type bufferConfig struct {
 	errorHandler  func(err error, elements [][]byte)
 }
 
+// PutRecordsResultHook is called with the request and response of every PutRecords call.
+// out is usually nil when err is not nil.
+type PutRecordsResultHook func(in *kinesis.PutRecordsInput, out *kinesis.PutRecordsOutput, err error)
+
 // WriterConfigOption is a configuration option for a Writer.
 type WriterConfigOption func(*writerConfig)
 
@@ -49,6 +155,7 @@ type WriterConfigOption func(*writerConfig)
 func WithSplitFunc(fn bufio.SplitFunc) WriterConfigOption {
 	return func(c *writerConfig) {
 		c.splitFunc = fn
+		c.scanLines = false
 	}
 }
 
@@ -74,6 +181,7 @@ func WithBufferWriteTimeout(timeout time.Duration) WriterConfigOption {
 }
 
 // WithBufferFlushTimeout sets the flush timeout for the buffer.
+// It is also the default flush deadline. See WithFlushDeadline.
 func WithBufferFlushTimeout(timeout time.Duration) WriterConfigOption {
 	return func(c *writerConfig) {
 		c.bufferConfig.flushTimeout = timeout
@@ -88,8 +196,639 @@ func WithBufferFlushInterval(interval time.Duration) WriterConfigOption {
 }
 
 // WithBufferErrorHandler sets the error handler for the buffer.
+// Delivery failures are passed as a *FlushError, whose Details carry the attempt count
+// and first-seen time of each failed record.
 func WithBufferErrorHandler(handler func(err error, elements [][]byte)) WriterConfigOption {
 	return func(c *writerConfig) {
 		c.bufferConfig.errorHandler = handler
 	}
 }
+
+// WithImmediateFlush disables buffering. Each Write sends its records
+// synchronously and returns delivery errors to the caller.
+// A record window of 1 has the same effect.
+func WithImmediateFlush() WriterConfigOption {
+	return func(c *writerConfig) {
+		c.immediateFlush = true
+	}
+}
+
+// WithPutRecordsResultHook sets a hook called after every PutRecords call, including retries.
+func WithPutRecordsResultHook(hook PutRecordsResultHook) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.resultHook = hook
+	}
+}
+
+// WithPutRecordsMiddleware appends middlewares around each PutRecords call.
+// The first middleware is the outermost one.
+func WithPutRecordsMiddleware(middlewares ...PutRecordsMiddleware) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.middlewares = append(c.middlewares, middlewares...)
+	}
+}
+
+// WithFailureWindow sets the rolling window used by FailureRate. Flushes are counted in
+// 60 time buckets, so they leave the window in steps of a sixtieth of it.
+func WithFailureWindow(window time.Duration) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.failureWindow = window
+	}
+}
+
+// WithSlowFlushThreshold sets a callback fired when a flush, including its retries,
+// takes longer than threshold.
+func WithSlowFlushThreshold(threshold time.Duration, callback func(elapsed time.Duration, records int)) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.slowFlush = &slowFlushConfig{
+			threshold: threshold,
+			callback:  callback,
+		}
+	}
+}
+
+// WithPutRecordsTimeout sets the timeout of a single PutRecords attempt.
+// An attempt that times out is retried as long as the flush deadline allows.
+func WithPutRecordsTimeout(timeout time.Duration) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.putRecordsTimeout = timeout
+	}
+}
+
+// WithFlushDeadline sets the overall time limit of a flush, including all retries.
+// The default is the buffer flush timeout.
+func WithFlushDeadline(deadline time.Duration) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.flushDeadline = deadline
+	}
+}
+
+// WithRetryBackoffByErrorCode sets retry backoffs per PutRecords error code,
+// e.g. "ProvisionedThroughputExceededException" or "InternalFailure".
+// When failed records have several error codes, the longest delay is used.
+// Error codes without an entry use the default backoff. It has no effect with WithRetrier.
+func WithRetryBackoffByErrorCode(backoffs map[string]RetryBackoff) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.retryBackoffs = backoffs
+	}
+}
+
+// WithMaxInFlightBatches bounds the number of batches delivered to Kinesis at the same time.
+// Batches run concurrently when Writes race in immediate flush mode, or when a flush
+// keeps running in the background after the buffer flush timeout expired.
+// When n batches are in flight, further flushes wait for a slot, applying backpressure
+// to the buffer and to writers. Zero means no bound.
+func WithMaxInFlightBatches(n int) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.maxInFlight = n
+	}
+}
+
+// WithHotShardWarning sets a callback fired when a single shard accepted more than threshold
+// (0 to 1) of the last sampleSize records, which usually means skewed partition keys.
+// Shards are identified from PutRecords results. The number of open shards is taken from the
+// shard map of WithRoundRobinHashKeys, or listed in the background every minute when the
+// Kinesis client implements KinesisShardLister, so that streams with one shard never warn.
+// Until it is known, the stream is assumed to have several shards.
+func WithHotShardWarning(threshold float64, sampleSize int, callback func(shardID string, share float64)) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.hotShard = &hotShardConfig{threshold: threshold, sampleSize: sampleSize, callback: callback}
+	}
+}
+
+// WithRoundRobinHashKeys distributes records evenly over the open shards of the stream
+// by cycling ExplicitHashKey values through the shard hash key ranges. Shards are listed with
+// ListShards and refreshed every minute to follow reshards, so the Kinesis client must
+// implement KinesisShardLister. When PutRecords fails with ResourceInUseException or reports
+// shards that were not listed, the shards are refreshed at once, and records get plain
+// partition keys until the refresh succeeds.
+func WithRoundRobinHashKeys() WriterConfigOption {
+	return func(c *writerConfig) {
+		c.roundRobinHashKey = true
+	}
+}
+
+// WithPartitionKeySeed generates partition keys from a random source seeded with seed
+// instead of the global one, so that the keys of a run are reproducible.
+func WithPartitionKeySeed(seed int64) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.partitionKeys = newSeededPartitioner(seed)
+	}
+}
+
+// WithPartitioner sets the Partitioner deciding the partition key and explicit hash key of records.
+// Records written with a partition key of their own, e.g. by WriteEntries, keep it.
+func WithPartitioner(p Partitioner) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.partitionKeys = p
+	}
+}
+
+// WithSequentialPartitionKeys generates partition keys from a counter starting at 0,
+// which is handy for asserting requests in tests.
+// Note that sequential keys still spread over shards as Kinesis hashes them.
+func WithSequentialPartitionKeys() WriterConfigOption {
+	return func(c *writerConfig) {
+		c.partitionKeys = &sequentialPartitioner{}
+	}
+}
+
+// WithTee also writes every record, followed by a newline, to w, e.g. os.Stdout or a local file.
+// A failing tee does not stop records from being sent to Kinesis.
+func WithTee(w io.Writer) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.tee = w
+	}
+}
+
+// WithRequestLogger logs every PutRecords call at debug level to logger, with the AWS request ID,
+// the batch size, the duration and the number of failed records, so that support cases
+// can reference exact requests.
+func WithRequestLogger(logger *slog.Logger) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.requestLogger = logger
+	}
+}
+
+// WithPreserveOrder keeps records in write order across partial failures.
+// A flush, including the retries of its failed records, completes before the next one starts,
+// so retried records are never sent after newer records. Records that still fail after retries
+// go to the error handler before the next flush. The buffer flush timeout no longer abandons
+// a slow flush; the flush deadline bounds it instead.
+func WithPreserveOrder() WriterConfigOption {
+	return func(c *writerConfig) {
+		c.preserveOrder = true
+	}
+}
+
+// WithDeliveryAuditSink sends a receipt with the record hash, sequence number and shard ID
+// of every accepted record to sink.
+func WithDeliveryAuditSink(sink DeliveryAuditSink) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.auditSink = sink
+	}
+}
+
+// WithArchive also stores every record in gzip compressed, newline delimited objects uploaded
+// with uploader, e.g. to S3, in parallel with Kinesis delivery. Objects are keyed by prefix and
+// the UTC hour they started in, and are uploaded every interval or once they hold 64 MiB of records.
+// Zero interval means 5 minutes.
+func WithArchive(uploader ArchiveUploader, prefix string, interval time.Duration) WriterConfigOption {
+	return func(c *writerConfig) {
+		if interval <= 0 {
+			interval = defaultArchiveInterval
+		}
+		c.archive = &archiveConfig{
+			uploader: uploader,
+			prefix:   prefix,
+			interval: interval,
+		}
+	}
+}
+
+// WithRecordValidator adds a validator run on every record before it is buffered.
+// Records failing validation go to the reject handler instead of Kinesis.
+func WithRecordValidator(validator RecordValidator) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.validators = append(c.validators, validator)
+	}
+}
+
+// WithJSONSchema validates every record against the JSON Schema document schema
+// before it is buffered. Records that are not valid JSON or do not match the schema
+// go to the reject handler. New returns an error if schema does not compile.
+func WithJSONSchema(schema string) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.jsonSchema = schema
+	}
+}
+
+// WithRejectHandler sets the handler of records failing validation.
+// The default handler prints them to stderr.
+func WithRejectHandler(handler RejectHandler) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.rejectHandler = handler
+	}
+}
+
+// WithTransform adds a transform applied to every record before validation, e.g. LogfmtToJSON.
+// Transforms run in the order they are added.
+func WithTransform(transform Transform) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.transforms = append(c.transforms, transform)
+	}
+}
+
+// WithStrictNDJSON rejects records that are not exactly one JSON document on a single line.
+// See ValidateNDJSON.
+func WithStrictNDJSON() WriterConfigOption {
+	return WithRecordValidator(ValidateNDJSON)
+}
+
+// WithMultiline groups continuation lines into the record started by the preceding line
+// matching start. See ScanMultiline.
+func WithMultiline(start *regexp.Regexp) WriterConfigOption {
+	return WithSplitFunc(ScanMultiline(start))
+}
+
+// WithLatencySLO tracks the latency from writing each record to its acceptance by Kinesis
+// and calls callback when the 99th percentile over the latest records goes over target.
+// The callback is called again only after the percentile has recovered in between.
+func WithLatencySLO(target time.Duration, callback func(p99 time.Duration)) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.latency = newLatencyTracker(target, callback)
+	}
+}
+
+// WithPriorityFunc chooses the priority lane of each record written with Write and ReadFrom
+// from its content, after transforms. For example, fn can return PriorityHigh for records
+// whose JSON "level" field is "error".
+func WithPriorityFunc(fn func(record []byte) Priority) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.priorityFunc = fn
+	}
+}
+
+// WithPermanentErrorCodes replaces the error codes that are not retried, such as
+// AccessDeniedException, ResourceNotFoundException and ValidationException by default.
+// Records failing with them go straight to the buffer error handler or are returned as
+// an error without using the retry budget. Service errors with other codes are retried.
+func WithPermanentErrorCodes(codes ...string) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.permanentErrors = make(map[string]struct{}, len(codes))
+		for _, code := range codes {
+			c.permanentErrors[code] = struct{}{}
+		}
+	}
+}
+
+// WithFlushResultHook sets a hook called with the result of every flush of a batch,
+// after its retries.
+func WithFlushResultHook(hook func(FlushResult)) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.flushHook = hook
+	}
+}
+
+// WithBuffer replaces the bundled buffer with the Buffer created by newBuffer,
+// e.g. a disk-backed or sharded one. newBuffer is called once for each priority lane.
+// The WithBuffer* options configure the bundled buffer only.
+func WithBuffer(newBuffer NewBufferFunc) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.newBuffer = newBuffer
+	}
+}
+
+// WithRetrier replaces the retry policy for failed records with retrier,
+// e.g. to use an organization standard backoff library.
+func WithRetrier(retrier Retrier) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.retrier = retrier
+	}
+}
+
+// WithAdaptiveRetry configures the Kinesis client created by the package with the adaptive
+// retry mode of the SDK, whose client-side token bucket slows down calls while they are
+// throttled. Failed calls are then left to the SDK instead of being retried again by the writer,
+// which only retries the records failed within accepted calls. Configure the retryer of a client
+// given with WithKinesisClient yourself.
+func WithAdaptiveRetry() WriterConfigOption {
+	return func(c *writerConfig) {
+		c.adaptiveRetry = true
+	}
+}
+
+// WithNoRetry disables retries, so that records failed by their first attempt go straight to
+// the buffer error handler, or are returned as a *FlushError when flushed immediately.
+func WithNoRetry() WriterConfigOption {
+	return WithRetrier(noRetrier{})
+}
+
+// ClosePolicy controls the flushes of the records remaining when the writer is closed,
+// apart from the steady-state retry policy and deadline.
+type ClosePolicy struct {
+	// Retrier replaces the Retrier, e.g. to retry longer or not at all. Nil keeps the usual one.
+	Retrier Retrier
+	// FlushDeadline replaces the flush deadline when it is positive.
+	FlushDeadline time.Duration
+	// Discard hands the remaining records to the buffer error handler with ErrDiscarded,
+	// e.g. to write them to a dead letter queue, instead of sending them.
+	Discard bool
+}
+
+// WithClosePolicy sets the policy for the flushes made while the writer is closed.
+func WithClosePolicy(policy ClosePolicy) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.closePolicy = &policy
+	}
+}
+
+// WithStatsReporter calls report with a snapshot of the Stats every interval,
+// and once more when the writer is closed, e.g. to bridge them to a metrics system.
+// New fails unless interval is positive.
+func WithStatsReporter(interval time.Duration, report func(Stats)) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.statsReport = &statsReportConfig{
+			interval: interval,
+			report:   report,
+		}
+	}
+}
+
+// WithMemoryLimit sheds normal priority records while the memory in use is at or over limit bytes,
+// passing them to the reject handler with ErrMemoryPressure instead of buffering them.
+// High priority records are never shed. gauge returns the memory in use; when it is nil,
+// the heap allocation reported by runtime.ReadMemStats is used. The gauge is read at most
+// every 100ms.
+func WithMemoryLimit(limit uint64, gauge func() uint64) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.memoryShedder = newMemoryShedder(limit, gauge)
+	}
+}
+
+// WithSkipEmptyRecords drops empty records, such as blank lines, instead of sending them.
+// This is the default.
+func WithSkipEmptyRecords() WriterConfigOption {
+	return func(c *writerConfig) {
+		c.allowEmptyRecords = false
+	}
+}
+
+// WithAllowEmptyRecords sends empty records as they are.
+func WithAllowEmptyRecords() WriterConfigOption {
+	return func(c *writerConfig) {
+		c.allowEmptyRecords = true
+	}
+}
+
+// WithKeepCarriageReturn keeps the \r ending lines written with CRLF, which is stripped
+// by default. It replaces the split function.
+func WithKeepCarriageReturn() WriterConfigOption {
+	return func(c *writerConfig) {
+		c.splitFunc = scanRawLines
+		c.scanLines = false
+	}
+}
+
+// WithHoldPartialLine holds the data after the last newline of a Write until a following
+// Write completes the line, instead of sending it as a record right away. The held data is
+// sent when the writer is closed.
+func WithHoldPartialLine() WriterConfigOption {
+	return func(c *writerConfig) {
+		c.holdPartialLine = true
+	}
+}
+
+// WithOversizePolicy sets how records over the 1MiB Kinesis record size limit are handled.
+// The default is OversizeError.
+func WithOversizePolicy(policy OversizePolicy) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.oversizePolicy = policy
+	}
+}
+
+// WithRedactor sets how the default reject handler and buffer error handler render records,
+// e.g. RedactHash, to keep record contents such as personal data out of the host logs.
+// Records are printed as they are by default.
+func WithRedactor(redactor Redactor) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.redactor = redactor
+	}
+}
+
+// WithHeartbeat writes the record returned by payload every interval, so that consumers can
+// tell an idle producer from a dead one. Heartbeats go to the high priority lane and skip
+// transforms and validation. New fails unless interval is positive.
+func WithHeartbeat(interval time.Duration, payload func() []byte) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.heartbeat = &heartbeatConfig{
+			interval: interval,
+			payload:  payload,
+		}
+	}
+}
+
+// WithCanary writes a timestamped canary record every interval through the buffer, and calls
+// callback when Kinesis accepts it, approximating the end-to-end latency of the pipeline from
+// the producer side. Canary records are JSON objects with "kinesiswriter_canary" and "sent_at"
+// fields that consumers can filter out, and they skip transforms and validation.
+// New fails unless interval is positive.
+func WithCanary(interval time.Duration, callback func(CanaryResult)) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.canary = &canary{callback: callback}
+		c.canaryInterval = interval
+	}
+}
+
+// WithScaleOutSignal sets a callback fired when at least threshold (0 to 1) of the record
+// deliveries attempted over window were throttled, a sign that the stream needs more shards.
+// The callback can publish a custom metric or page an operator; it should not block, as it
+// runs on the flush path. When the Kinesis client implements KinesisShardLister, the shards are
+// listed, and updated with WithAutoScaleOut, in the background before the callback runs.
+// Signals fired meanwhile are reported without listing the shards.
+func WithScaleOutSignal(threshold float64, window time.Duration, callback func(ScaleSignal)) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.scaleSignal = &scaleSignalConfig{threshold: threshold, window: window, callback: callback}
+	}
+}
+
+// WithAutoScaleOut permits the writer to call UpdateShardCount when WithScaleOutSignal fires,
+// doubling the open shards up to maxShards. The Kinesis client must implement
+// KinesisShardLister and KinesisShardUpdater.
+func WithAutoScaleOut(maxShards int) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.autoScaleMaxShards = maxShards
+	}
+}
+
+// WithBatchAdvisor logs suggestions for the buffer options every sampleSize flushes,
+// e.g. when batches chronically hold one or two records, fill the record window,
+// or take close to the flush timeout. It is meant for tuning, not for production.
+func WithBatchAdvisor(sampleSize int) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.advisorSample = sampleSize
+	}
+}
+
+// WithStreamARN overrides the stream ARN passed to New. It is meant for Clone and Factory.New
+// overrides deriving writers for other streams from one base configuration.
+func WithStreamARN(streamARN string) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.streamARN = streamARN
+	}
+}
+
+// WithTenantKey labels records with the tenant returned by key. Records of a tenant share
+// the tenant as their partition key, and Stats.Tenants counts deliveries per tenant.
+// Records for which key returns "" are not attributed to any tenant.
+func WithTenantKey(key func(record []byte) string) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.tenantKey = key
+	}
+}
+
+// WithTenantRateLimit limits the records of each tenant set by WithTenantKey to
+// recordsPerSecond, with bursts of up to burst records. Records over the limit are passed to
+// the reject handler with ErrTenantRateLimited, so that one noisy tenant cannot use up
+// the stream throughput shared with the others. The limiter of a tenant is dropped once
+// it refilled to burst, so that idle tenants take no memory.
+func WithTenantRateLimit(recordsPerSecond float64, burst int) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.tenantLimiter = newTenantLimiter(recordsPerSecond, burst)
+	}
+}
+
+// WithStreamKeyFunc sends each record to the stream that streams maps its key to,
+// or to the stream of the writer if the key is not mapped. Every stream has its own buffer
+// and deliveries, while Stats and MemoryUsage cover all of them. Routed records share
+// one priority lane per stream.
+func WithStreamKeyFunc(key func(record []byte) string, streams map[string]string) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.streamKey = key
+		c.streams = streams
+	}
+}
+
+// WithFatalErrorChannel sends the error returned by Writer.Err to ch once it occurs.
+// The send does not block, so ch should be buffered.
+func WithFatalErrorChannel(ch chan<- error) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.fatalCh = ch
+	}
+}
+
+// WithManualFlush replaces the buffer with one that flushes only on Sync, FlushSync and Close,
+// synchronously and in batches of the record window, returning delivery errors to the caller.
+// Without timers nor background goroutines, tests of code using the writer are deterministic.
+// Options writing records on their own schedule, such as WithHeartbeat, still do.
+func WithManualFlush() WriterConfigOption {
+	return func(c *writerConfig) {
+		c.newBuffer = func(flush func(records []Record) error) Buffer {
+			return &manualBuffer{flush: flush, window: max(int(c.bufferConfig.recordWindow), 1)}
+		}
+	}
+}
+
+// WithCodec sets the codec encoding the values of WriteValue. The default is JSONCodec.
+func WithCodec(codec Codec) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.codec = codec
+		c.codecName, c.codecNameErr = "", nil
+	}
+}
+
+// WithBatchManifest sends a BatchManifest record after each flushed batch, so that consumers can
+// verify they received every record. Manifests go to manifestStreamARN, or to the stream of
+// the batch if it is empty, with a single PutRecords attempt; failures are only logged.
+// Each manifest costs an extra PutRecords call made synchronously after the flush, which delays
+// the next flush of the buffer and counts against the stream's request limits.
+// Consumers can tell manifest records apart with ParseBatchManifest.
+func WithBatchManifest(manifestStreamARN string) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.manifest = true
+		c.manifestStream = manifestStreamARN
+	}
+}
+
+// WithWriteDeadline bounds the time a write to the buffer may block on backpressure, when
+// the buffer is full because flushes cannot keep up. Past the deadline, the write stops and
+// returns a *WriteTimeoutError with the records and bytes not accepted, instead of waiting up to
+// the buffer write timeout for each record. The buffer write timeout is lowered to d if longer.
+// Immediate flush mode is not affected.
+func WithWriteDeadline(d time.Duration) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.writeDeadline = d
+	}
+}
+
+// WithWarmup makes New describe the stream, and the streams of WithStreamKeyFunc, with
+// DescribeStreamSummary before returning, so that credentials, networking, DNS and the stream
+// itself are checked before real traffic flows. New fails unless the streams are active or
+// updating. The Kinesis client must implement KinesisStreamDescriber. Note that describing a
+// stream does not prove the kinesis:PutRecords permission.
+func WithWarmup() WriterConfigOption {
+	return func(c *writerConfig) {
+		c.warmup = true
+	}
+}
+
+// WithErrorOutput writes the events of the default reject handler and buffer error handler to w
+// in format instead of standard error, e.g. to a RotatingFile. It has no effect on handlers set
+// with WithRejectHandler or WithBufferErrorHandler.
+func WithErrorOutput(w io.Writer, format ErrorFormat) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.errorOutput = w
+		c.errorFormat = format
+	}
+}
+
+// WithErrorLogger logs the events of the default reject handler and buffer error handler to
+// logger, rejected records at warn level and failed ones at error level, instead of writing
+// them to standard error. It takes precedence over WithErrorOutput.
+func WithErrorLogger(logger *slog.Logger) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.errorLogger = logger
+	}
+}
+
+// mutationPanicRecordSize is how much of a mutated record the default mutation guard panic renders.
+const mutationPanicRecordSize = 256
+
+// WithMutationGuard is a debugging aid hashing every record when it is written and verifying
+// the hash when it is flushed, to catch code changing record data in between, e.g. a
+// Transform reusing its output buffer, a RecordValidator editing records in place, or a custom
+// Buffer. Mutated records are passed to report with ErrRecordMutated, or
+// make the flush panic if report is nil, and are sent as they are. The panic message renders
+// the record with the Redactor, truncated to 256 bytes. Hashing costs CPU on every
+// record, so it is meant for tests and debugging.
+func WithMutationGuard(report RejectHandler) WriterConfigOption {
+	return func(c *writerConfig) {
+		if report == nil {
+			report = func(err error, record []byte) {
+				panic(fmt.Sprintf("%s: %s", err, RedactTruncate(mutationPanicRecordSize)([]byte(c.redactor(record)))))
+			}
+		}
+		c.mutationGuard = report
+	}
+}
+
+// WithCodecName sets the codec registered as name, e.g. "gob", as the codec of WriteValue,
+// and reports name in encoding announcements. New fails if no codec is registered as name.
+func WithCodecName(name string) WriterConfigOption {
+	return func(c *writerConfig) {
+		codec, ok := LookupCodec(name)
+		if !ok {
+			c.codecNameErr = fmt.Errorf("unknown codec %q", name)
+			return
+		}
+		c.codec, c.codecName, c.codecNameErr = codec, name, nil
+	}
+}
+
+// WithEncodingAnnouncement writes an EncodingAnnouncement record when the writer is created and
+// every interval, so that consumers can configure decoding from the stream itself. Announcements
+// are JSON objects with a "kinesiswriter_encoding" field that ParseEncodingAnnouncement
+// recognizes. They go to the high priority lane and skip transforms and validation.
+// New fails unless interval is positive.
+func WithEncodingAnnouncement(interval time.Duration) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.announce, c.announceInterval = true, interval
+	}
+}
+
+// WithWriterID identifies the writer as id in delivery receipts, Stats, batch manifests,
+// encoding announcements and request logs, so that the records of several producer instances
+// writing to one stream can be told apart downstream.
+func WithWriterID(id string) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.writerID, c.writerIDGenerator = id, nil
+	}
+}
+
+// WithWriterIDGenerator is like WithWriterID with an ID returned by generate, which is called
+// once per writer, so that writers created from shared options such as a Factory get their own ID.
+func WithWriterIDGenerator(generate func() string) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.writerID, c.writerIDGenerator = "", generate
+	}
+}
@@ -0,0 +1,71 @@
+package kinesiswriter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Copier continuously copies records from an io.Reader, such as a process output,
+// a net.Conn or a pipe, to a Kinesis stream.
+type Copier struct {
+	writer *Writer
+	done   chan struct{}
+	err    error
+}
+
+// NewCopier creates a Writer for streamARN and starts copying records from r in a goroutine
+// until EOF or until ctx is canceled. Cancellation is observed between reads,
+// so close r to interrupt a blocking read. The writer is closed when copying ends.
+func NewCopier(ctx context.Context, streamARN string, r io.Reader, opts ...WriterConfigOption) (*Copier, error) {
+	w, err := New(ctx, streamARN, opts...)
+	if err != nil {
+		return nil, err
+	}
+	c := &Copier{
+		writer: w,
+		done:   make(chan struct{}),
+	}
+	go c.run(ctx, r)
+	return c, nil
+}
+
+func (c *Copier) run(ctx context.Context, r io.Reader) {
+	defer close(c.done)
+	_, err := c.writer.ReadFrom(&contextReader{ctx: ctx, r: r})
+	if closeErr := c.writer.Close(); closeErr != nil {
+		err = errors.Join(err, fmt.Errorf("failed to close writer: %w", closeErr))
+	}
+	c.err = err
+}
+
+// Wait blocks until copying ends and returns its error.
+// It returns nil when r reached EOF and all records were handed to the writer.
+func (c *Copier) Wait() error {
+	<-c.done
+	return c.err
+}
+
+// Err returns the error of copying once it has ended, or nil while it is still running.
+func (c *Copier) Err() error {
+	select {
+	case <-c.done:
+		return c.err
+	default:
+		return nil
+	}
+}
+
+// contextReader stops reading once ctx is done.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
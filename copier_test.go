@@ -0,0 +1,75 @@
+package kinesiswriter_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	kinesiswriter "github.com/mackee/go-kinesis-writer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopier(t *testing.T) {
+	ctx := context.Background()
+	client := &successKinesisClient{}
+	pr, pw := io.Pipe()
+	copier, err := kinesiswriter.NewCopier(ctx, testStreamARN, pr,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithImmediateFlush(),
+	)
+	require.NoError(t, err)
+
+	_, err = pw.Write([]byte("rec"))
+	require.NoError(t, err)
+	_, err = pw.Write([]byte("ord1\nrecord2\n"))
+	require.NoError(t, err)
+	assert.NoError(t, copier.Err())
+	require.NoError(t, pw.Close())
+	require.NoError(t, copier.Wait())
+
+	var records []string
+	for _, input := range client.Inputs() {
+		for _, entry := range input.Records {
+			records = append(records, string(entry.Data))
+		}
+	}
+	assert.Equal(t, []string{"record1", "record2"}, records)
+}
+
+func TestCopierCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	copier, err := kinesiswriter.NewCopier(ctx, testStreamARN, strings.NewReader("record1\n"),
+		kinesiswriter.WithKinesisClient(&successKinesisClient{}),
+	)
+	require.NoError(t, err)
+	assert.ErrorIs(t, copier.Wait(), context.Canceled)
+}
+
+func TestCopierGzip(t *testing.T) {
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	_, err := zw.Write([]byte("record1\nrecord2\n"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	client := &successKinesisClient{}
+	copier, err := kinesiswriter.NewCopier(context.Background(), testStreamARN, &compressed,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithImmediateFlush(),
+	)
+	require.NoError(t, err)
+	require.NoError(t, copier.Wait())
+
+	var records []string
+	for _, input := range client.Inputs() {
+		for _, entry := range input.Records {
+			records = append(records, string(entry.Data))
+		}
+	}
+	assert.Equal(t, []string{"record1", "record2"}, records)
+}
@@ -0,0 +1,69 @@
+package kinesiswriter
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// EncryptJSONFields returns a Transform encrypting the values of the top-level fields named
+// fields in JSON records with aead, leaving the other fields, such as routing keys, in the clear.
+// An encrypted value is replaced with a string of the base64 encoded nonce and ciphertext,
+// authenticated with the field name. To use a KMS data key, create aead from its plaintext with
+// aes.NewCipher and cipher.NewGCM. Records that are not a single JSON object, including null,
+// fail to transform, so that no record is sent unencrypted.
+func EncryptJSONFields(aead cipher.AEAD, fields ...string) Transform {
+	return func(record []byte) ([]byte, error) {
+		dec := json.NewDecoder(bytes.NewReader(record))
+		dec.UseNumber()
+		var obj map[string]json.RawMessage
+		if err := dec.Decode(&obj); err != nil {
+			return nil, fmt.Errorf("invalid JSON object: %w", err)
+		}
+		if obj == nil {
+			return nil, errors.New("invalid JSON object: null")
+		}
+		if rest := bytes.TrimSpace(record[dec.InputOffset():]); len(rest) > 0 {
+			return nil, errors.New("invalid JSON object: trailing data after JSON document")
+		}
+		for _, field := range fields {
+			value, ok := obj[field]
+			if !ok {
+				continue
+			}
+			nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(value)+aead.Overhead())
+			if _, err := rand.Read(nonce); err != nil {
+				return nil, fmt.Errorf("failed to generate nonce: %w", err)
+			}
+			sealed := aead.Seal(nonce, nonce, value, []byte(field))
+			encrypted, err := json.Marshal(base64.StdEncoding.EncodeToString(sealed))
+			if err != nil {
+				return nil, err
+			}
+			obj[field] = encrypted
+		}
+		return json.Marshal(obj)
+	}
+}
+
+// DecryptJSONField decrypts a value of field encrypted by EncryptJSONFields
+// and returns the original JSON value.
+func DecryptJSONField(aead cipher.AEAD, field, value string) ([]byte, error) {
+	sealed, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode encrypted value: %w", err)
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("encrypted value too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, []byte(field))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt value: %w", err)
+	}
+	return plain, nil
+}
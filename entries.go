@@ -0,0 +1,53 @@
+package kinesiswriter
+
+import (
+	"bytes"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis/types"
+)
+
+// WriteEntries writes prebuilt entries, keeping their partition keys and explicit hash keys.
+// Entries are buffered, batched and retried like the records of Write, but skip transforms
+// and validation. Entries without a partition key are partitioned as configured.
+// Entries over the record size limit, counting the partition key, are passed to the reject
+// handler with ErrRecordTooLarge, and those with a partition key over 256 characters with
+// ErrInvalidPartitionKey.
+func (w *Writer) WriteEntries(entries []types.PutRecordsRequestEntry) error {
+	w.closeMu.RLock()
+	defer w.closeMu.RUnlock()
+	if w.stopped() {
+		return ErrWriterClosed
+	}
+	now := time.Now()
+	records := make([]Record, 0, len(entries))
+	data := make([][]byte, 0, len(entries))
+	for _, entry := range entries {
+		key := aws.ToString(entry.PartitionKey)
+		if len(entry.Data)+len(key) > maxRecordSize {
+			w.config.rejectHandler(ErrRecordTooLarge, entry.Data)
+			continue
+		}
+		if utf8.RuneCountInString(key) > maxPartitionKeySize {
+			w.config.rejectHandler(ErrInvalidPartitionKey, entry.Data)
+			continue
+		}
+		record := Record{
+			Data:            bytes.Clone(entry.Data),
+			EnqueuedAt:      now,
+			PartitionKey:    key,
+			ExplicitHashKey: entry.ExplicitHashKey,
+		}
+		records = append(records, record)
+		data = append(data, record.Data)
+	}
+	if w.config.tee != nil {
+		w.writeTee(data)
+	}
+	if w.archiver != nil {
+		w.archiver.add(data)
+	}
+	return w.enqueue(records, w.config.priorityFunc)
+}
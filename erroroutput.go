@@ -0,0 +1,209 @@
+package kinesiswriter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// ErrorFormat is the output format of the default reject handler and buffer error handler.
+type ErrorFormat int
+
+const (
+	// ErrorFormatText writes human-readable lines, rendering records with the Redactor.
+	ErrorFormatText ErrorFormat = iota
+	// ErrorFormatNDJSON writes a JSON object per line in the format of RecordingClient,
+	// with "error" and "kind" fields added, so that LoadRecordedInputs can load the records
+	// for replay. Records are written with random partition keys and without stream, and whole
+	// unless WithRedactor is set, in which case their rendering by the Redactor is written.
+	ErrorFormatNDJSON
+)
+
+// handlerOutput receives the events of the default reject handler and buffer error handler.
+type handlerOutput interface {
+	bufferError(err error, records [][]byte)
+	reject(err error, record []byte)
+}
+
+// defaultOutput returns the output of the default handlers, standard error unless configured.
+func (c *writerConfig) defaultOutput() handlerOutput {
+	if c.errorLogger != nil {
+		return loggerOutput{logger: c.errorLogger, redact: c.redactor}
+	}
+	if c.errorOutput != nil {
+		return &errorOutput{w: c.errorOutput, format: c.errorFormat, redact: c.redactor}
+	}
+	return &errorOutput{w: os.Stderr, redact: c.redactor}
+}
+
+// errorLine is a line of ErrorFormatNDJSON output.
+type errorLine struct {
+	Error string `json:"error"`
+	// Kind is "rejected" for the reject handler and "failed" for the buffer error handler.
+	Kind string `json:"kind"`
+	recordedInput
+}
+
+// errorOutput renders the events of the default handlers to a writer.
+type errorOutput struct {
+	w      io.Writer
+	format ErrorFormat
+	redact Redactor
+	// mu keeps the lines of concurrent events apart.
+	mu sync.Mutex
+}
+
+func (o *errorOutput) bufferError(err error, records [][]byte) {
+	var buf bytes.Buffer
+	if o.format == ErrorFormatNDJSON {
+		o.encode(&buf, err, "failed", records)
+	} else {
+		fmt.Fprintf(&buf, "async-buffer: error %s\n", err)
+		for i, record := range records {
+			fmt.Fprintf(&buf, "failed to write logs [%d]=%s\n", i, o.redact(record))
+		}
+	}
+	o.write(buf.Bytes())
+}
+
+func (o *errorOutput) reject(err error, record []byte) {
+	var buf bytes.Buffer
+	if o.format == ErrorFormatNDJSON {
+		o.encode(&buf, err, "rejected", [][]byte{record})
+	} else {
+		fmt.Fprintf(&buf, "rejected record: %s: %s\n", err, o.redact(record))
+	}
+	o.write(buf.Bytes())
+}
+
+func (o *errorOutput) encode(buf *bytes.Buffer, err error, kind string, records [][]byte) {
+	line := errorLine{Error: err.Error(), Kind: kind}
+	line.Records = make([]recordedEntry, len(records))
+	for i, record := range records {
+		key, _ := randomPartitioner{}.Key(record)
+		line.Records[i] = recordedEntry{Data: []byte(o.redact(record)), PartitionKey: key}
+	}
+	_ = json.NewEncoder(buf).Encode(line)
+}
+
+// write writes p at once, so that a rotating writer rotates between events.
+func (o *errorOutput) write(p []byte) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	_, _ = o.w.Write(p)
+}
+
+// loggerOutput logs the events of the default handlers.
+type loggerOutput struct {
+	logger *slog.Logger
+	redact Redactor
+}
+
+func (o loggerOutput) bufferError(err error, records [][]byte) {
+	rendered := make([]string, len(records))
+	for i, record := range records {
+		rendered[i] = o.redact(record)
+	}
+	o.logger.LogAttrs(context.Background(), slog.LevelError, "failed to write records",
+		slog.String("error", err.Error()), slog.Any("records", rendered))
+}
+
+func (o loggerOutput) reject(err error, record []byte) {
+	o.logger.LogAttrs(context.Background(), slog.LevelWarn, "rejected record",
+		slog.String("error", err.Error()), slog.String("record", o.redact(record)))
+}
+
+// RotatingFile is an io.WriteCloser appending to a file, which is renamed with a ".1" suffix
+// once a write would take it over a size limit, shifting older files up to a number of backups.
+type RotatingFile struct {
+	path     string
+	maxBytes int64
+	backups  int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// NewRotatingFile opens path for appending, creating it if needed. The file is rotated before
+// a write would take it over maxBytes, keeping backups rotated files.
+func NewRotatingFile(path string, maxBytes int64, backups int) (*RotatingFile, error) {
+	f := &RotatingFile{path: path, maxBytes: maxBytes, backups: backups}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", f.path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat %s: %w", f.path, err)
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+// Write appends p to the file, rotating it first if p does not fit.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.size > 0 && f.size+int64(len(p)) > f.maxBytes {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate shifts the rotated files, dropping the oldest one, and starts a new file.
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %w", f.path, err)
+	}
+	f.file = nil
+	if f.backups <= 0 {
+		if err := os.Remove(f.path); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", f.path, err)
+		}
+		return f.open()
+	}
+	for i := f.backups - 1; i > 0; i-- {
+		src := f.path + "." + strconv.Itoa(i)
+		if err := os.Rename(src, f.path+"."+strconv.Itoa(i+1)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rotate %s: %w", src, err)
+		}
+	}
+	if err := os.Rename(f.path, f.path+".1"); err != nil {
+		return fmt.Errorf("failed to rotate %s: %w", f.path, err)
+	}
+	return f.open()
+}
+
+// Close closes the file.
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return os.ErrClosed
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
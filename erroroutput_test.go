@@ -0,0 +1,102 @@
+package kinesiswriter_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/smithy-go"
+	kinesiswriter "github.com/mackee/go-kinesis-writer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterErrorOutput(t *testing.T) {
+	rejectOdd := kinesiswriter.WithRecordValidator(func(record []byte) error {
+		if string(record) == "odd" {
+			return errors.New("odd record")
+		}
+		return nil
+	})
+	write := func(t *testing.T, opts ...kinesiswriter.WriterConfigOption) {
+		t.Helper()
+		writer, err := kinesiswriter.New(context.Background(), testStreamARN, append([]kinesiswriter.WriterConfigOption{
+			kinesiswriter.WithKinesisClient(&errorKinesisClient{err: &smithy.GenericAPIError{Code: "AccessDeniedException"}}),
+			kinesiswriter.WithNoRetry(),
+			rejectOdd,
+		}, opts...)...)
+		require.NoError(t, err)
+		_, err = writer.Write([]byte("record1\nodd\nrecord2\n"))
+		require.NoError(t, err)
+		// records flushed by Close return their error instead of passing it to the handler.
+		require.NoError(t, writer.Wait(context.Background()))
+		require.NoError(t, writer.Close())
+	}
+
+	t.Run("ndjson", func(t *testing.T) {
+		var out bytes.Buffer
+		write(t, kinesiswriter.WithErrorOutput(&out, kinesiswriter.ErrorFormatNDJSON))
+		inputs, err := kinesiswriter.LoadRecordedInputs(&out)
+		require.NoError(t, err)
+		var records []string
+		for _, input := range inputs {
+			for _, entry := range input.Records {
+				records = append(records, string(entry.Data))
+				assert.NotEmpty(t, *entry.PartitionKey)
+			}
+		}
+		// the rejected record comes first, and the failed ones may be flushed in several batches.
+		assert.Equal(t, []string{"odd", "record1", "record2"}, records)
+	})
+	t.Run("ndjson redacted", func(t *testing.T) {
+		var out bytes.Buffer
+		write(t,
+			kinesiswriter.WithErrorOutput(&out, kinesiswriter.ErrorFormatNDJSON),
+			kinesiswriter.WithRedactor(kinesiswriter.RedactOmit),
+		)
+		inputs, err := kinesiswriter.LoadRecordedInputs(&out)
+		require.NoError(t, err)
+		var records []string
+		for _, input := range inputs {
+			for _, entry := range input.Records {
+				records = append(records, string(entry.Data))
+			}
+		}
+		assert.Equal(t, []string{"<3 bytes>", "<7 bytes>", "<7 bytes>"}, records)
+	})
+	t.Run("text", func(t *testing.T) {
+		var out bytes.Buffer
+		write(t, kinesiswriter.WithErrorOutput(&out, kinesiswriter.ErrorFormatText))
+		assert.Contains(t, out.String(), "rejected record: odd record: odd\n")
+		assert.Regexp(t, `async-buffer: error .*AccessDeniedException.*\nfailed to write logs \[0\]=record1\n`, out.String())
+	})
+	t.Run("logger", func(t *testing.T) {
+		var out bytes.Buffer
+		write(t, kinesiswriter.WithErrorLogger(slog.New(slog.NewTextHandler(&out, nil))))
+		assert.Contains(t, out.String(), `level=WARN msg="rejected record" error="odd record" record=odd`)
+		assert.Contains(t, out.String(), `level=ERROR msg="failed to write records"`)
+	})
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "errors.log")
+	f, err := kinesiswriter.NewRotatingFile(path, 10, 1)
+	require.NoError(t, err)
+	for _, line := range []string{"line1\n", "line2\n", "line3\n"} {
+		_, err := f.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	require.NoError(t, f.Close())
+
+	current, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "line3\n", string(current))
+	rotated, err := os.ReadFile(path + ".1")
+	require.NoError(t, err)
+	assert.Equal(t, "line2\n", string(rotated))
+	assert.NoFileExists(t, path+".2")
+}
@@ -0,0 +1,126 @@
+package kinesiswriter
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+)
+
+var (
+	// ErrThrottled is matched by a FlushError whose records were throttled by Kinesis.
+	ErrThrottled = errors.New("kinesiswriter: throttled")
+	// ErrRecordTooLarge is passed to the reject handler for records over the Kinesis record size limit.
+	ErrRecordTooLarge = errors.New("kinesiswriter: record too large")
+	// ErrStreamNotFound is matched by a FlushError when the stream does not exist.
+	ErrStreamNotFound = errors.New("kinesiswriter: stream not found")
+	// ErrWriterClosed is returned when writing to or closing a closed Writer.
+	ErrWriterClosed = errors.New("kinesiswriter: writer closed")
+	// ErrMemoryPressure is passed to the reject handler for records shed because the memory
+	// in use is over the limit set by WithMemoryLimit.
+	ErrMemoryPressure = errors.New("kinesiswriter: memory pressure")
+	// ErrDiscarded is passed to the buffer error handler for records discarded by Close
+	// as configured by ClosePolicy.
+	ErrDiscarded = errors.New("kinesiswriter: discarded on close")
+	// ErrTenantRateLimited is passed to the reject handler for records over the rate limit
+	// of their tenant set by WithTenantRateLimit.
+	ErrTenantRateLimited = errors.New("kinesiswriter: tenant rate limited")
+	// ErrInvalidPartitionKey is passed to the reject handler for entries written with
+	// WriteEntries whose partition key is over 256 characters.
+	ErrInvalidPartitionKey = errors.New("kinesiswriter: invalid partition key")
+	// ErrRecordMutated is reported by WithMutationGuard for records whose data changed
+	// between their write and their flush.
+	ErrRecordMutated = errors.New("kinesiswriter: record mutated after write")
+)
+
+// throttlingErrorCodes are the error codes matching ErrThrottled.
+var throttlingErrorCodes = []string{
+	provisionedThroughputExceeded,
+	"ThrottlingException",
+	"LimitExceededException",
+	"KMSThrottlingException",
+}
+
+// FlushError reports the records that could not be delivered by a flush.
+// It matches ErrThrottled and ErrStreamNotFound with errors.Is depending on the error codes.
+type FlushError struct {
+	// Attempts is the number of PutRecords attempts made.
+	Attempts int
+	// FailedRecords are the records that were not accepted.
+	FailedRecords [][]byte
+	// ErrorCodes are the error codes of the last failure of each of FailedRecords.
+	ErrorCodes []string
+	// Details describe the delivery attempts of each of FailedRecords.
+	Details []FailedRecord
+	// Err is the error of the last PutRecords call when the call itself failed.
+	Err error
+}
+
+// WriteTimeoutError is returned by writes that gave up at the deadline set by WithWriteDeadline.
+// The records before NotAcceptedRecords were accepted by the buffer and are delivered as usual.
+type WriteTimeoutError struct {
+	Deadline time.Duration
+	// NotAcceptedRecords is the number of records the buffer did not accept.
+	NotAcceptedRecords int
+	// NotAcceptedBytes is the data size of those records.
+	NotAcceptedBytes int
+}
+
+func newWriteTimeoutError(deadline time.Duration, records []Record) *WriteTimeoutError {
+	return &WriteTimeoutError{
+		Deadline:           deadline,
+		NotAcceptedRecords: len(records),
+		NotAcceptedBytes:   recordsSize(recordsData(records)),
+	}
+}
+
+func (e *WriteTimeoutError) Error() string {
+	return fmt.Sprintf("write deadline of %s exceeded: %d records (%d bytes) not accepted", e.Deadline, e.NotAcceptedRecords, e.NotAcceptedBytes)
+}
+
+// Unwrap returns os.ErrDeadlineExceeded.
+func (e *WriteTimeoutError) Unwrap() error {
+	return os.ErrDeadlineExceeded
+}
+
+// Timeout reports true, as for net.Error.
+func (e *WriteTimeoutError) Timeout() bool {
+	return true
+}
+
+// FailedRecord describes a record that a flush could not deliver.
+type FailedRecord struct {
+	Data []byte
+	// ErrorCode is the error code of the last failure.
+	ErrorCode string
+	// Attempts is the number of PutRecords attempts that included the record.
+	Attempts int
+	// FirstSeenAt is when the record was written to the Writer.
+	FirstSeenAt time.Time
+}
+
+func (e *FlushError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("failed to put records: %s", e.Err)
+	}
+	return fmt.Sprintf("failed to put records: %d records are failed", len(e.FailedRecords))
+}
+
+func (e *FlushError) Unwrap() []error {
+	var errs []error
+	if e.Err != nil {
+		errs = append(errs, e.Err)
+	}
+	if slices.ContainsFunc(e.ErrorCodes, isThrottling) {
+		errs = append(errs, ErrThrottled)
+	}
+	if slices.Contains(e.ErrorCodes, "ResourceNotFoundException") {
+		errs = append(errs, ErrStreamNotFound)
+	}
+	return errs
+}
+
+func isThrottling(code string) bool {
+	return slices.Contains(throttlingErrorCodes, code)
+}
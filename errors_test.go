@@ -0,0 +1,177 @@
+package kinesiswriter_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/smithy-go"
+	kinesiswriter "github.com/mackee/go-kinesis-writer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterFlushError(t *testing.T) {
+	tests := []struct {
+		name     string
+		client   testKinesisClient
+		target   error
+		attempts int
+		codes    []string
+	}{
+		{
+			name:     "stream not found",
+			client:   &errorKinesisClient{err: &smithy.GenericAPIError{Code: "ResourceNotFoundException"}},
+			target:   kinesiswriter.ErrStreamNotFound,
+			attempts: 1,
+			codes:    []string{"ResourceNotFoundException", "ResourceNotFoundException"},
+		},
+		{
+			name:     "throttled",
+			client:   &errorKinesisClient{err: &smithy.GenericAPIError{Code: "ProvisionedThroughputExceededException"}},
+			target:   kinesiswriter.ErrThrottled,
+			attempts: 4,
+			codes:    []string{"ProvisionedThroughputExceededException", "ProvisionedThroughputExceededException"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+				kinesiswriter.WithKinesisClient(tt.client),
+				kinesiswriter.WithImmediateFlush(),
+				kinesiswriter.WithRetryBackoffByErrorCode(map[string]kinesiswriter.RetryBackoff{
+					"ProvisionedThroughputExceededException": {MinDelay: time.Millisecond, MaxDelay: time.Millisecond},
+				}),
+			)
+			require.NoError(t, err)
+			start := time.Now()
+			_, err = writer.Write([]byte("record1\nrecord2\n"))
+
+			var flushErr *kinesiswriter.FlushError
+			require.ErrorAs(t, err, &flushErr)
+			assert.Equal(t, tt.attempts, flushErr.Attempts)
+			assert.Equal(t, tt.codes, flushErr.ErrorCodes)
+			assert.Len(t, flushErr.FailedRecords, len(tt.codes))
+			require.Len(t, flushErr.Details, len(tt.codes))
+			for i, d := range flushErr.Details {
+				assert.Equal(t, flushErr.FailedRecords[i], d.Data)
+				assert.Equal(t, tt.codes[i], d.ErrorCode)
+				assert.Equal(t, tt.attempts, d.Attempts)
+				assert.False(t, d.FirstSeenAt.Before(start))
+			}
+			if tt.target != nil {
+				assert.ErrorIs(t, err, tt.target)
+			}
+			require.NoError(t, writer.Close())
+		})
+	}
+}
+
+func TestWriterRecordTooLarge(t *testing.T) {
+	client := &successKinesisClient{}
+	var rejected []error
+	writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithImmediateFlush(),
+		kinesiswriter.WithSplitFunc(func(data []byte, atEOF bool) (int, []byte, error) {
+			if !atEOF || len(data) == 0 {
+				return 0, nil, nil
+			}
+			return len(data), data, nil
+		}),
+		kinesiswriter.WithRejectHandler(func(err error, record []byte) {
+			rejected = append(rejected, err)
+		}),
+	)
+	require.NoError(t, err)
+	_, err = writer.Write(bytes.Repeat([]byte("a"), 1024*1024+1))
+	require.NoError(t, err)
+	// no room for the partition key.
+	_, err = writer.Write(bytes.Repeat([]byte("a"), 1024*1024-100))
+	require.NoError(t, err)
+	_, err = writer.ReadFrom(bytes.NewReader(bytes.Repeat([]byte("a"), 2*1024*1024)))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	assert.Empty(t, client.Inputs())
+	require.Len(t, rejected, 3)
+	for _, err := range rejected {
+		assert.ErrorIs(t, err, kinesiswriter.ErrRecordTooLarge)
+	}
+}
+
+func TestWriterReadFromRecordTooLarge(t *testing.T) {
+	client := &successKinesisClient{}
+	var rejected [][]byte
+	writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithImmediateFlush(),
+		kinesiswriter.WithRejectHandler(func(err error, record []byte) {
+			assert.ErrorIs(t, err, kinesiswriter.ErrRecordTooLarge)
+			rejected = append(rejected, record)
+		}),
+	)
+	require.NoError(t, err)
+	large := strings.Repeat("a", 2*1024*1024)
+	_, err = writer.ReadFrom(strings.NewReader("record1\n" + large + "\nrecord2\n"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	var records []string
+	for _, input := range client.Inputs() {
+		for _, entry := range input.Records {
+			records = append(records, string(entry.Data))
+		}
+	}
+	assert.Equal(t, []string{"record1", "record2"}, records)
+	assert.Equal(t, [][]byte{[]byte(large)}, rejected)
+}
+
+func TestWriterClosed(t *testing.T) {
+	writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+		kinesiswriter.WithKinesisClient(&successKinesisClient{}),
+	)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	_, err = writer.Write([]byte("record1\n"))
+	assert.ErrorIs(t, err, kinesiswriter.ErrWriterClosed)
+	assert.ErrorIs(t, writer.Close(), kinesiswriter.ErrWriterClosed)
+}
+
+func TestWriterErr(t *testing.T) {
+	tests := []struct {
+		name  string
+		code  string
+		fatal bool
+	}{
+		{name: "permanent", code: "AccessDeniedException", fatal: true},
+		{name: "retryable", code: "InternalFailure"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ch := make(chan error, 1)
+			writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+				kinesiswriter.WithKinesisClient(&errorKinesisClient{err: &smithy.GenericAPIError{Code: tt.code}}),
+				kinesiswriter.WithBufferErrorHandler(func(error, [][]byte) {}),
+				kinesiswriter.WithNoRetry(),
+				kinesiswriter.WithFatalErrorChannel(ch),
+			)
+			require.NoError(t, err)
+			require.NoError(t, writer.Err())
+			_, err = writer.Write([]byte("record1\nrecord2"))
+			require.NoError(t, err)
+			_ = writer.Close()
+
+			if !tt.fatal {
+				assert.NoError(t, writer.Err())
+				assert.Empty(t, ch)
+				return
+			}
+			var flushErr *kinesiswriter.FlushError
+			require.ErrorAs(t, writer.Err(), &flushErr)
+			assert.Equal(t, []string{tt.code, tt.code}, flushErr.ErrorCodes)
+			assert.Same(t, flushErr, <-ch)
+		})
+	}
+}
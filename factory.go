@@ -0,0 +1,34 @@
+package kinesiswriter
+
+import (
+	"context"
+	"slices"
+)
+
+// Factory creates Writers sharing one Kinesis client and default options.
+type Factory struct {
+	opts []WriterConfigOption
+}
+
+// NewFactory creates a new Factory with default options for every writer it creates.
+// Unless WithKinesisClient is given, a client is created once from the default AWS config.
+func NewFactory(ctx context.Context, opts ...WriterConfigOption) (*Factory, error) {
+	conf := &writerConfig{bufferConfig: &bufferConfig{}}
+	for _, opt := range opts {
+		opt(conf)
+	}
+	opts = slices.Clone(opts)
+	if conf.client == nil {
+		client, err := newDefaultClient(ctx, conf.adaptiveRetry)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithKinesisClient(client))
+	}
+	return &Factory{opts: opts}, nil
+}
+
+// New creates a new Writer for streamARN. overrides are applied after the default options.
+func (f *Factory) New(ctx context.Context, streamARN string, overrides ...WriterConfigOption) (*Writer, error) {
+	return New(ctx, streamARN, slices.Concat(f.opts, overrides)...)
+}
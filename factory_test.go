@@ -0,0 +1,35 @@
+package kinesiswriter_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	kinesiswriter "github.com/mackee/go-kinesis-writer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFactory(t *testing.T) {
+	ctx := context.Background()
+	client := &successKinesisClient{}
+	factory, err := kinesiswriter.NewFactory(ctx,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithImmediateFlush(),
+	)
+	require.NoError(t, err)
+
+	var slow int
+	w, err := factory.New(ctx, stream1ARN,
+		kinesiswriter.WithSlowFlushThreshold(0, func(elapsed time.Duration, records int) { slow += records }),
+	)
+	require.NoError(t, err)
+	_, err = w.Write([]byte("record1"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	require.Len(t, client.Inputs(), 1)
+	assert.Equal(t, stream1ARN, aws.ToString(client.Inputs()[0].StreamARN))
+	assert.Equal(t, 1, slow)
+}
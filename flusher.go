@@ -2,86 +2,480 @@ package kinesiswriter
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
-	"math/rand"
-	"strconv"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/kinesis"
 	"github.com/aws/aws-sdk-go-v2/service/kinesis/types"
-	"github.com/shogo82148/go-retry"
 )
 
+// defaultPermanentErrorCodes are the error codes that retrying does not resolve.
+var defaultPermanentErrorCodes = []string{
+	"AccessDeniedException",
+	"ResourceNotFoundException",
+	"ValidationException",
+	"InvalidArgumentException",
+	"KMSAccessDeniedException",
+	"KMSDisabledException",
+	"KMSInvalidStateException",
+	"KMSNotFoundException",
+	"KMSOptInRequired",
+}
+
+// FlushResult is the outcome of flushing a batch of records, including its retries.
+type FlushResult struct {
+	// Sent is the number of records accepted by Kinesis.
+	Sent int
+	// Failed is the number of records that could not be delivered.
+	Failed int
+	// Retries is the number of PutRecords attempts after the first one.
+	Retries int
+	// Duration is the time spent on the flush.
+	Duration time.Duration
+	// ByteSize is the data size of the batch.
+	ByteSize int
+	// PerErrorCode counts the failed records by the error code of their last failure.
+	PerErrorCode map[string]int
+}
+
+// Flusher delivers batches of records to a Kinesis stream with the partitioning, retries and
+// partial failure handling of a Writer, for use with a queueing layer of your own.
+// It implements the Flusher interface of github.com/woorui/async-buffer for [][]byte elements.
+type Flusher struct {
+	flusher *flusher
+}
+
+// NewFlusher creates a Flusher for streamARN. opts are the same as for New, and
+// options about splitting, buffering and validating records have no effect.
+func NewFlusher(ctx context.Context, streamARN string, opts ...WriterConfigOption) (*Flusher, error) {
+	if _, err := ParseStreamARN(streamARN); err != nil {
+		return nil, err
+	}
+	conf, err := newWriterConfig(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	fl, err := newFlusher(conf, streamARN)
+	if err != nil {
+		return nil, err
+	}
+	return &Flusher{flusher: fl}, nil
+}
+
+// Flush sends records with PutRecords calls of up to 500 records and 5MiB, retrying the failed
+// ones, which are chunked again within the same limits.
+// It returns a *FlushError when some records could not be delivered.
+func (f *Flusher) Flush(records [][]byte) error {
+	f.flusher.health.enqueued(len(records), recordsSize(records))
+	return f.flusher.Flush(records)
+}
+
+// Stats returns a snapshot of the delivery counters.
+func (f *Flusher) Stats() Stats {
+	return f.flusher.stats.snapshot()
+}
+
 type flusher struct {
-	client       KinesisClient
-	flushTimeout time.Duration
-	streamARN    string
+	putRecords        PutRecordsFunc
+	flushDeadline     time.Duration
+	putRecordsTimeout time.Duration
+	streamARN         string
+	resultHook        PutRecordsResultHook
+	flushHook         func(FlushResult)
+	health            *health
+	slowFlush         *slowFlushConfig
+	retrier           Retrier
+	// sdkRetriesCalls leaves retrying failed calls to the SDK.
+	sdkRetriesCalls bool
+	permanentErrors map[string]struct{}
+	inFlight        chan struct{}
+	stats           *stats
+	hotShard        *hotShardDetector
+	scaleOut        *scaleOutDetector
+	advisor         *batchAdvisor
+	tenantKey       func(record []byte) string
+	// fatal is the first flush error with a permanent error code.
+	fatal       atomic.Pointer[FlushError]
+	fatalCh     chan<- error
+	partitioner Partitioner
+	manifests   *batchManifests
+	// mutationGuard reports records mutated after their write, if set.
+	mutationGuard RejectHandler
+	// shardMap is the partitioner following the shards of the stream, if any.
+	shardMap     *roundRobinPartitioner
+	auditSink    DeliveryAuditSink
+	writerID     string
+	latency      *latencyTracker
+	errorHandler func(err error, records [][]byte)
+	closePolicy  *ClosePolicy
+	canary       *canary
+	// closing is set by Close to switch to the close policy.
+	closing atomic.Bool
+	// orderMu serializes flushes when the record order is preserved.
+	orderMu *sync.Mutex
 }
 
+// Flush sends records enqueued now, retrying failed ones.
 func (f *flusher) Flush(records [][]byte) error {
+	return f.flushRecords(newRecords(records))
+}
+
+// flushRecords sends buffered records, retrying failed ones.
+func (f *flusher) flushRecords(records []Record) error {
+	if f.orderMu != nil {
+		f.orderMu.Lock()
+		defer f.orderMu.Unlock()
+	}
+	if f.inFlight != nil {
+		f.inFlight <- struct{}{}
+		defer func() { <-f.inFlight }()
+	}
+	if f.mutationGuard != nil {
+		f.verifyGuard(records)
+	}
+	data := recordsData(records)
+	if f.closing.Load() && f.closePolicy != nil && f.closePolicy.Discard {
+		f.health.flushed(len(records), recordsSize(data), nil)
+		f.errorHandler(ErrDiscarded, data)
+		return nil
+	}
+	start := time.Now()
+	result, err := f.flush(records)
+	result.Duration = time.Since(start)
+	result.ByteSize = recordsSize(data)
+	f.health.flushed(len(records), result.ByteSize, err)
+	f.checkFatal(err)
+	if f.manifests != nil {
+		f.sendManifest(records, err)
+	}
+	if f.slowFlush != nil {
+		if result.Duration > f.slowFlush.threshold {
+			f.slowFlush.callback(result.Duration, len(records))
+		}
+	}
+	if f.advisor != nil {
+		f.advisor.observe(len(records), result.Duration)
+	}
+	if f.flushHook != nil {
+		f.flushHook(result)
+	}
+	return err
+}
+
+func (f *flusher) flush(records []Record) (FlushResult, error) {
+	retrier, flushDeadline := f.retrier, f.flushDeadline
+	if f.closing.Load() && f.closePolicy != nil {
+		if f.closePolicy.Retrier != nil {
+			retrier = f.closePolicy.Retrier
+		}
+		if f.closePolicy.FlushDeadline > 0 {
+			flushDeadline = f.closePolicy.FlushDeadline
+		}
+	}
 	ctx := context.Background()
-	ctx, cancel := context.WithTimeout(ctx, f.flushTimeout)
+	ctx, cancel := context.WithTimeout(ctx, flushDeadline)
 	defer cancel()
-	failedRecords, err := f.putRecords(ctx, records)
-	if err != nil {
-		return fmt.Errorf("failed to put records: %w", err)
+	var (
+		failedRecords []Record
+		failedCodes   []string
+		failedTries   []int
+		retry         = records
+		retryCodes    []string
+		attempts      int
+		err           error
+	)
+	for len(retry) > 0 {
+		if attempts > 0 {
+			delay, ok := retrier.NextDelay(attempts, retryCodes)
+			if !ok {
+				break
+			}
+			if delay > 0 {
+				if err := sleepContext(ctx, delay); err != nil {
+					break
+				}
+			}
+			log.Printf("retry to put records: %d records are failed", len(retry))
+		}
+		attempts++
+		chunks := chunkRecords(retry)
+		retry, retryCodes, err = nil, nil, nil
+		for _, chunk := range chunks {
+			failed, codes, chunkErr := f.attempt(ctx, chunk)
+			if chunkErr != nil {
+				failed = chunk
+				codes = repeatCode(errorCode(chunkErr), len(chunk))
+				err = chunkErr
+			}
+			for i, record := range failed {
+				if f.retryable(codes[i], chunkErr != nil) {
+					retry = append(retry, record)
+					retryCodes = append(retryCodes, codes[i])
+				} else {
+					failedRecords = append(failedRecords, record)
+					failedCodes = append(failedCodes, codes[i])
+					failedTries = append(failedTries, attempts)
+				}
+			}
+		}
+	}
+
+	failedRecords = append(failedRecords, retry...)
+	failedCodes = append(failedCodes, retryCodes...)
+	for range retry {
+		failedTries = append(failedTries, attempts)
+	}
+	result := FlushResult{
+		Sent:    len(records) - len(failedRecords),
+		Failed:  len(failedRecords),
+		Retries: max(attempts-1, 0),
 	}
 	if len(failedRecords) == 0 {
-		return nil
+		return result, nil
 	}
-	retryPolicy := retry.Policy{
-		MinDelay: 5 * time.Second,
-		MaxDelay: f.flushTimeout,
-		MaxCount: 3,
+	result.PerErrorCode = make(map[string]int)
+	for _, code := range failedCodes {
+		result.PerErrorCode[code]++
 	}
-	retrier := retryPolicy.Start(ctx)
-	for retrier.Continue() {
-		log.Printf("retry to put records: %d records are failed", len(failedRecords))
-		var err error
-		failedRecords, err = f.putRecords(ctx, failedRecords)
-		if err != nil {
-			return fmt.Errorf("failed to put records: %w", err)
+	details := make([]FailedRecord, len(failedRecords))
+	for i, r := range failedRecords {
+		details[i] = FailedRecord{
+			Data:        r.Data,
+			ErrorCode:   failedCodes[i],
+			Attempts:    failedTries[i],
+			FirstSeenAt: r.EnqueuedAt,
 		}
-		if len(failedRecords) == 0 {
-			break
+	}
+	return result, &FlushError{
+		Attempts:      attempts,
+		FailedRecords: recordsData(failedRecords),
+		ErrorCodes:    failedCodes,
+		Details:       details,
+		Err:           err,
+	}
+}
+
+// chunkRecords splits records into chunks within the PutRecords limits of 500 records and 5MiB,
+// counting the largest partition key for each record. Failed records are chunked again on retry.
+func chunkRecords(records []Record) [][]Record {
+	var chunks [][]Record
+	start, size := 0, 0
+	for i, r := range records {
+		n := len(r.Data) + maxPartitionKeySize
+		if i > start && (i-start == maxRequestRecords || size+n > maxRequestSize) {
+			chunks = append(chunks, records[start:i])
+			start, size = i, 0
 		}
+		size += n
+	}
+	if start < len(records) {
+		chunks = append(chunks, records[start:])
 	}
+	return chunks
+}
 
-	if len(failedRecords) > 0 {
-		return fmt.Errorf("failed to put records: %d records are failed", len(failedRecords))
+// checkFatal keeps the first flush error caused by a permanent error code,
+// which retries and later flushes cannot recover from.
+func (f *flusher) checkFatal(err error) {
+	var flushErr *FlushError
+	if !errors.As(err, &flushErr) {
+		return
 	}
+	if !slices.ContainsFunc(flushErr.ErrorCodes, func(code string) bool {
+		_, ok := f.permanentErrors[code]
+		return ok
+	}) {
+		return
+	}
+	if f.fatal.CompareAndSwap(nil, flushErr) && f.fatalCh != nil {
+		select {
+		case f.fatalCh <- flushErr:
+		default:
+		}
+	}
+}
+
+// retryable reports whether a record failed with code is worth retrying.
+// Permanent codes are not retried. A failed call is retried only for service errors,
+// since other errors, such as network errors, are already retried by the SDK.
+func (f *flusher) retryable(code string, callFailed bool) bool {
+	if _, ok := f.permanentErrors[code]; ok {
+		return false
+	}
+	if callFailed && f.sdkRetriesCalls {
+		return false
+	}
+	return !callFailed || code != unknownErrorCode
+}
 
-	return nil
+// attempt sends records once within the per-attempt timeout.
+// When only the attempt times out, all records are returned as failed so that they are retried
+// while the flush deadline allows.
+func (f *flusher) attempt(ctx context.Context, records []Record) ([]Record, []string, error) {
+	if f.putRecordsTimeout <= 0 {
+		return f.sendRecords(ctx, records)
+	}
+	attemptCtx, cancel := context.WithTimeout(ctx, f.putRecordsTimeout)
+	defer cancel()
+	failedRecords, errorCodes, err := f.sendRecords(attemptCtx, records)
+	if err != nil && errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+		log.Printf("put records attempt timed out after %s", f.putRecordsTimeout)
+		return records, repeatCode(unknownErrorCode, len(records)), nil
+	}
+	return failedRecords, errorCodes, err
 }
 
-func (f *flusher) putRecords(ctx context.Context, records [][]byte) ([][]byte, error) {
+// sendRecords calls PutRecords once and returns the failed records with their error codes.
+func (f *flusher) sendRecords(ctx context.Context, records []Record) ([]Record, []string, error) {
 	entries := make([]types.PutRecordsRequestEntry, len(records))
 	for i, r := range records {
-		key := rand.Int()
+		key, hashKey := r.PartitionKey, r.ExplicitHashKey
+		if key == "" {
+			key, hashKey = f.partitioner.Key(r.Data)
+		}
 		entries[i] = types.PutRecordsRequestEntry{
-			Data:         r,
-			PartitionKey: aws.String(strconv.Itoa(key)),
+			Data:            r.Data,
+			PartitionKey:    aws.String(key),
+			ExplicitHashKey: hashKey,
 		}
 	}
 
-	ret, err := f.client.PutRecords(ctx, &kinesis.PutRecordsInput{
+	input := &kinesis.PutRecordsInput{
 		Records:   entries,
 		StreamARN: aws.String(f.streamARN),
-	})
+	}
+	ret, err := f.putRecords(ctx, input)
+	if f.resultHook != nil {
+		f.resultHook(input, ret, err)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to put records: %w", err)
+		f.stats.failed(errorCode(err), len(records))
+		if f.shardMap != nil && errorCode(err) == "ResourceInUseException" {
+			f.shardMap.invalidate()
+		}
+		if f.scaleOut != nil {
+			n := 0
+			if isThrottling(errorCode(err)) {
+				n = len(records)
+			}
+			f.scaleOut.observe(len(records), n)
+		}
+		return nil, nil, fmt.Errorf("failed to put records: %w", err)
+	}
+	var shardIDs []string
+	// accepted records count in the latency whatever the outcome of the rest of the flush.
+	var acceptedAt []time.Time
+	now := time.Now()
+	for i, rr := range ret.Records {
+		var tenant string
+		if f.tenantKey != nil {
+			tenant = f.tenantKey(records[i].Data)
+		}
+		f.stats.result(rr, len(records[i].Data), tenant)
+		if rr.ErrorCode == nil && rr.ShardId != nil {
+			shardIDs = append(shardIDs, *rr.ShardId)
+		}
+		if f.canary != nil && rr.ErrorCode == nil {
+			f.canary.accepted(records[i].Data, rr, now)
+		}
+		if f.latency != nil && rr.ErrorCode == nil {
+			acceptedAt = append(acceptedAt, records[i].EnqueuedAt)
+		}
+	}
+	if len(acceptedAt) > 0 {
+		f.latency.observe(acceptedAt)
+	}
+	if f.hotShard != nil {
+		f.hotShard.observe(shardIDs)
+	}
+	if f.shardMap != nil {
+		f.shardMap.observe(shardIDs)
+	}
+	if f.scaleOut != nil {
+		f.scaleOut.observe(len(records), throttled(ret.Records))
+	}
+	if f.auditSink != nil {
+		f.recordDeliveries(records, ret.Records)
 	}
 	if ret.FailedRecordCount == nil || *ret.FailedRecordCount == 0 {
-		return nil, nil
+		return nil, nil, nil
 	}
 
-	failedRecords := make([][]byte, 0, *ret.FailedRecordCount)
+	failedRecords := make([]Record, 0, *ret.FailedRecordCount)
+	errorCodes := make([]string, 0, *ret.FailedRecordCount)
 	for i, rr := range ret.Records {
 		if rr.ErrorCode != nil {
 			failedRecords = append(failedRecords, records[i])
+			errorCodes = append(errorCodes, *rr.ErrorCode)
+		}
+	}
+	return failedRecords, errorCodes, nil
+}
+
+// recordDeliveries sends receipts of the accepted records to the audit sink.
+func (f *flusher) recordDeliveries(records []Record, results []types.PutRecordsResultEntry) {
+	now := time.Now()
+	receipts := make([]DeliveryReceipt, 0, len(results))
+	for i, rr := range results {
+		if rr.ErrorCode != nil {
+			continue
 		}
+		receipts = append(receipts, DeliveryReceipt{
+			RecordHash:     recordHash(records[i].Data),
+			SequenceNumber: aws.ToString(rr.SequenceNumber),
+			ShardID:        aws.ToString(rr.ShardId),
+			AcceptedAt:     now,
+			WriterID:       f.writerID,
+		})
+	}
+	if err := f.auditSink.RecordDeliveries(receipts); err != nil {
+		log.Printf("failed to record deliveries: %s", err)
+	}
+}
+
+func recordsSize(records [][]byte) int {
+	var size int
+	for _, r := range records {
+		size += len(r)
+	}
+	return size
+}
+
+// chainPutRecords wraps client.PutRecords with middlewares, the first one outermost.
+func chainPutRecords(client KinesisClient, middlewares []PutRecordsMiddleware) PutRecordsFunc {
+	fn := PutRecordsFunc(client.PutRecords)
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		fn = middlewares[i](fn)
+	}
+	return fn
+}
+
+// sleepContext sleeps for d unless ctx is done or its deadline comes first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+		return context.DeadlineExceeded
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// repeatCode returns n copies of code, for records failed all together.
+func repeatCode(code string, n int) []string {
+	codes := make([]string, n)
+	for i := range codes {
+		codes[i] = code
 	}
-	return failedRecords, nil
+	return codes
 }
@@ -0,0 +1,68 @@
+package kinesiswriter_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	kinesiswriter "github.com/mackee/go-kinesis-writer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	buffer "github.com/woorui/async-buffer"
+)
+
+func TestFlusher(t *testing.T) {
+	client := &successKinesisClient{}
+	flusher, err := kinesiswriter.NewFlusher(context.Background(), testStreamARN,
+		kinesiswriter.WithKinesisClient(client),
+	)
+	require.NoError(t, err)
+
+	var _ buffer.Flusher[[]byte] = flusher
+	require.NoError(t, flusher.Flush([][]byte{[]byte("record1"), []byte("record2")}))
+
+	require.Len(t, client.Inputs(), 1)
+	assert.Len(t, client.Inputs()[0].Records, 2)
+	assert.Equal(t, int64(2), flusher.Stats().RecordsSent)
+
+	client = &successKinesisClient{}
+	flusher, err = kinesiswriter.NewFlusher(context.Background(), testStreamARN,
+		kinesiswriter.WithKinesisClient(client),
+	)
+	require.NoError(t, err)
+	records := make([][]byte, 1200)
+	for i := range records {
+		records[i] = []byte("record")
+	}
+	for range 5 {
+		records = append(records, bytes.Repeat([]byte("a"), 1024*1024))
+	}
+	require.NoError(t, flusher.Flush(records))
+	var sizes []int
+	for _, input := range client.Inputs() {
+		sizes = append(sizes, len(input.Records))
+	}
+	assert.Equal(t, []int{500, 500, 204, 1}, sizes)
+
+	// the failed records of all chunks are retried in chunks again.
+	failing := &partialFailedKinesisClient{}
+	flusher, err = kinesiswriter.NewFlusher(context.Background(), testStreamARN,
+		kinesiswriter.WithKinesisClient(failing),
+		kinesiswriter.WithRetryBackoffByErrorCode(map[string]kinesiswriter.RetryBackoff{
+			"error": {MinDelay: time.Millisecond, MaxDelay: time.Millisecond},
+		}),
+	)
+	require.NoError(t, err)
+	require.Error(t, flusher.Flush(records[:1200]))
+	sizes = nil
+	for _, input := range failing.Inputs() {
+		sizes = append(sizes, len(input.Records))
+	}
+	assert.Equal(t, []int{500, 500, 200, 500, 100}, sizes[:5])
+
+	_, err = kinesiswriter.NewFlusher(context.Background(), "invalid",
+		kinesiswriter.WithKinesisClient(client),
+	)
+	assert.Error(t, err)
+}
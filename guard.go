@@ -0,0 +1,23 @@
+package kinesiswriter
+
+import "hash/maphash"
+
+// guardSeed seeds the record hashes of the mutation guard.
+var guardSeed = maphash.MakeSeed()
+
+// guard hashes the data of records to be verified by verifyGuard.
+func guard(records []Record) {
+	for i := range records {
+		records[i].sum = maphash.Bytes(guardSeed, records[i].Data)
+		records[i].guarded = true
+	}
+}
+
+// verifyGuard reports the records whose data changed since guard hashed them.
+func (f *flusher) verifyGuard(records []Record) {
+	for _, r := range records {
+		if r.guarded && maphash.Bytes(guardSeed, r.Data) != r.sum {
+			f.mutationGuard(ErrRecordMutated, r.Data)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package kinesiswriter
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var errBufferSaturated = errors.New("buffer is saturated")
+
+// health tracks flush outcomes and the number of records waiting for delivery.
+type health struct {
+	pending       atomic.Int64
+	pendingBytes  atomic.Int64
+	capacity      int64
+	failureWindow time.Duration
+
+	mu           sync.Mutex
+	lastErr      error
+	lastFailure  error
+	lastFailedAt time.Time
+	// outcomes counts flushes in fixed time buckets covering the failure window.
+	outcomes    [failureBuckets]flushOutcomes
+	bucketWidth time.Duration
+}
+
+// failureBuckets is the number of time buckets the failure window is divided into.
+const failureBuckets = 60
+
+// flushOutcomes counts the flushes of the time bucket slot.
+type flushOutcomes struct {
+	slot    int64
+	flushes int
+	failed  int
+}
+
+func newHealth(recordWindow uint32, failureWindow time.Duration) *health {
+	// async-buffer keeps up to twice the threshold in its channel.
+	capacity := int64(128)
+	if recordWindow != 0 {
+		capacity = int64(recordWindow) * 2
+	}
+	return &health{
+		capacity:      capacity,
+		failureWindow: failureWindow,
+		bucketWidth:   max(failureWindow/failureBuckets, time.Nanosecond),
+	}
+}
+
+func (h *health) enqueued(n, size int) {
+	h.pending.Add(int64(n))
+	h.pendingBytes.Add(int64(size))
+}
+
+func (h *health) flushed(n, size int, err error) {
+	h.pending.Add(-int64(n))
+	h.pendingBytes.Add(-int64(size))
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastErr = err
+	now := time.Now()
+	if err != nil {
+		h.lastFailure = err
+		h.lastFailedAt = now
+	}
+	slot := h.slot(now)
+	b := &h.outcomes[slot%failureBuckets]
+	if b.slot != slot {
+		*b = flushOutcomes{slot: slot}
+	}
+	b.flushes++
+	if err != nil {
+		b.failed++
+	}
+}
+
+// slot returns the number of the time bucket of t.
+func (h *health) slot(t time.Time) int64 {
+	return t.UnixNano() / int64(h.bucketWidth)
+}
+
+func (h *health) failureRate() float64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := h.slot(time.Now())
+	var flushes, failed int
+	for _, b := range h.outcomes {
+		if b.slot > now-failureBuckets {
+			flushes += b.flushes
+			failed += b.failed
+		}
+	}
+	if flushes == 0 {
+		return 0
+	}
+	return float64(failed) / float64(flushes)
+}
+
+func (h *health) lastError() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.lastErr
+}
+
+func (h *health) saturated() bool {
+	return h.pending.Load() >= h.capacity
+}
+
+// Healthy reports whether the writer is currently able to deliver records.
+// It returns false with the cause when the most recent flush failed or the buffer is saturated.
+func (w *Writer) Healthy() (bool, error) {
+	if err := w.flusher.health.lastError(); err != nil {
+		return false, err
+	}
+	if w.flusher.health.saturated() {
+		return false, errBufferSaturated
+	}
+	return true, nil
+}
+
+// LastError returns the most recent flush error, or nil if no flush has failed yet.
+func (w *Writer) LastError() error {
+	h := w.flusher.health
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.lastFailure
+}
+
+// LastErrorAt returns when the most recent flush error occurred.
+func (w *Writer) LastErrorAt() time.Time {
+	h := w.flusher.health
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.lastFailedAt
+}
+
+// FailureRate returns the ratio of failed flushes to all flushes within the failure window.
+// See WithFailureWindow.
+func (w *Writer) FailureRate() float64 {
+	return w.flusher.health.failureRate()
+}
+
+// HealthHandler returns an http.Handler for health checks such as /healthz.
+// It responds with 200 when the writer is healthy and 503 otherwise.
+func (w *Writer) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if ok, err := w.Healthy(); !ok {
+			http.Error(rw, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		rw.WriteHeader(http.StatusOK)
+		_, _ = rw.Write([]byte("ok\n"))
+	})
+}
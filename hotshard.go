@@ -0,0 +1,108 @@
+package kinesiswriter
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+type hotShardConfig struct {
+	threshold  float64
+	sampleSize int
+	callback   func(shardID string, share float64)
+}
+
+// hotShardDetector counts accepted records per shard and reports shards
+// receiving more than threshold of a sample.
+type hotShardDetector struct {
+	hotShardConfig
+	// shardCount returns the number of open shards of the stream, or 0 if unknown.
+	shardCount func() int
+
+	mu     sync.Mutex
+	total  int
+	counts map[string]int
+}
+
+func newHotShardDetector(conf hotShardConfig, shardCount func() int) *hotShardDetector {
+	if shardCount == nil {
+		shardCount = func() int { return 0 }
+	}
+	return &hotShardDetector{
+		hotShardConfig: conf,
+		shardCount:     shardCount,
+		counts:         make(map[string]int),
+	}
+}
+
+// observe adds accepted records of shardIDs to the current sample and evaluates it once full.
+func (d *hotShardDetector) observe(shardIDs []string) {
+	d.mu.Lock()
+	var hot map[string]float64
+	for _, id := range shardIDs {
+		d.counts[id]++
+		d.total++
+		if d.total < d.sampleSize {
+			continue
+		}
+		// a single shard stream is never skewed.
+		if d.shardCount() != 1 {
+			for shardID, n := range d.counts {
+				if share := float64(n) / float64(d.total); share > d.threshold {
+					if hot == nil {
+						hot = make(map[string]float64)
+					}
+					hot[shardID] = share
+				}
+			}
+		}
+		d.total = 0
+		clear(d.counts)
+	}
+	d.mu.Unlock()
+
+	for shardID, share := range hot {
+		d.callback(shardID, share)
+	}
+}
+
+// shardCounter caches the number of open shards of a stream,
+// listing them in the background at most once per refresh interval.
+type shardCounter struct {
+	lister          KinesisShardLister
+	streamARN       string
+	refreshInterval time.Duration
+
+	mu       sync.Mutex
+	count    int
+	listedAt time.Time
+	listing  bool
+}
+
+// get returns the known number of open shards, or 0 if they were never listed,
+// starting a listing when the count is older than the refresh interval.
+func (c *shardCounter) get() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.listing && time.Since(c.listedAt) >= c.refreshInterval {
+		c.listing, c.listedAt = true, time.Now()
+		go c.list()
+	}
+	return c.count
+}
+
+func (c *shardCounter) list() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	shards, err := listOpenShards(ctx, c.lister, c.streamARN)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.listing = false
+	if err != nil {
+		log.Printf("failed to list shards of %s, keep using %d known shards: %s", c.streamARN, c.count, err)
+		return
+	}
+	c.count = len(shards)
+}
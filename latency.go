@@ -0,0 +1,66 @@
+package kinesiswriter
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// latencySampleSize is the number of the latest record latencies the percentile is computed over.
+const latencySampleSize = 1024
+
+// latencyTracker keeps the latest latencies from enqueueing records to their acceptance
+// and calls back when their 99th percentile goes over the target.
+type latencyTracker struct {
+	target   time.Duration
+	callback func(p99 time.Duration)
+
+	mu       sync.Mutex
+	samples  []time.Duration
+	next     int
+	breached bool
+}
+
+func newLatencyTracker(target time.Duration, callback func(p99 time.Duration)) *latencyTracker {
+	return &latencyTracker{
+		target:   target,
+		callback: callback,
+		samples:  make([]time.Duration, 0, latencySampleSize),
+	}
+}
+
+// observe adds the latencies of records enqueued at enqueuedAt and accepted now.
+// The callback is called when the percentile goes over the target, and again only
+// after it has recovered in between.
+func (t *latencyTracker) observe(enqueuedAt []time.Time) {
+	now := time.Now()
+	t.mu.Lock()
+	for _, at := range enqueuedAt {
+		latency := now.Sub(at)
+		if len(t.samples) < latencySampleSize {
+			t.samples = append(t.samples, latency)
+			continue
+		}
+		t.samples[t.next] = latency
+		t.next = (t.next + 1) % latencySampleSize
+	}
+	p99 := t.percentile(0.99)
+	breached := p99 > t.target
+	fire := breached && !t.breached
+	t.breached = breached
+	t.mu.Unlock()
+
+	if fire {
+		t.callback(p99)
+	}
+}
+
+func (t *latencyTracker) percentile(p float64) time.Duration {
+	if len(t.samples) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(t.samples)
+	slices.Sort(sorted)
+	i := int(float64(len(sorted))*p+0.5) - 1
+	return sorted[max(i, 0)]
+}
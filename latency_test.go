@@ -0,0 +1,80 @@
+package kinesiswriter_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis/types"
+	kinesiswriter "github.com/mackee/go-kinesis-writer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterLatencySLO(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		alarms []time.Duration
+	)
+	writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+		kinesiswriter.WithKinesisClient(&successKinesisClient{}),
+		kinesiswriter.WithBufferRecordWindow(2),
+		kinesiswriter.WithPutRecordsMiddleware(func(next kinesiswriter.PutRecordsFunc) kinesiswriter.PutRecordsFunc {
+			return func(ctx context.Context, params *kinesis.PutRecordsInput, optFns ...func(*kinesis.Options)) (*kinesis.PutRecordsOutput, error) {
+				time.Sleep(100 * time.Millisecond)
+				return next(ctx, params, optFns...)
+			}
+		}),
+		kinesiswriter.WithLatencySLO(50*time.Millisecond, func(p99 time.Duration) {
+			mu.Lock()
+			defer mu.Unlock()
+			alarms = append(alarms, p99)
+		}),
+	)
+	require.NoError(t, err)
+
+	for range 3 {
+		_, err = writer.Write([]byte("record1\nrecord2\n"))
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, alarms, 1, "the alarm fires once while the latency stays over the target")
+	assert.GreaterOrEqual(t, alarms[0], 100*time.Millisecond)
+}
+
+func TestWriterLatencySLOPartialFailure(t *testing.T) {
+	var alarms []time.Duration
+	writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+		kinesiswriter.WithKinesisClient(&successKinesisClient{}),
+		kinesiswriter.WithImmediateFlush(),
+		kinesiswriter.WithNoRetry(),
+		kinesiswriter.WithBufferErrorHandler(func(error, [][]byte) {}),
+		kinesiswriter.WithPutRecordsMiddleware(func(next kinesiswriter.PutRecordsFunc) kinesiswriter.PutRecordsFunc {
+			return func(ctx context.Context, params *kinesis.PutRecordsInput, optFns ...func(*kinesis.Options)) (*kinesis.PutRecordsOutput, error) {
+				time.Sleep(100 * time.Millisecond)
+				return &kinesis.PutRecordsOutput{
+					FailedRecordCount: aws.Int32(1),
+					Records: []types.PutRecordsResultEntry{
+						{SequenceNumber: aws.String("1"), ShardId: aws.String("shardId-0")},
+						{ErrorCode: aws.String("InternalFailure"), ErrorMessage: aws.String("boom")},
+					},
+				}, nil
+			}
+		}),
+		kinesiswriter.WithLatencySLO(50*time.Millisecond, func(p99 time.Duration) {
+			alarms = append(alarms, p99)
+		}),
+	)
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("record1\nrecord2\n"))
+	require.Error(t, err)
+	require.Len(t, alarms, 1, "delivered records count even though the flush failed")
+	assert.GreaterOrEqual(t, alarms[0], 100*time.Millisecond)
+	_ = writer.Close()
+}
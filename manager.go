@@ -0,0 +1,380 @@
+package kinesiswriter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	buffer "github.com/woorui/async-buffer"
+)
+
+// managerTick is the resolution of the flush intervals of managed writers.
+const managerTick = 100 * time.Millisecond
+
+// ErrManagerClosed is returned by Manager.New after the manager is closed.
+var ErrManagerClosed = errors.New("manager is closed")
+
+// Manager creates Writers that share one flush scheduler and a fixed pool of flush workers,
+// instead of a timer and a goroutine per buffer, to bound the goroutines and timers of
+// processes running hundreds of writers. Flush intervals are checked every 100ms.
+//
+// Workers take batches from the buffers with pending batches in turn, and a buffer runs at most
+// its fair share of the workers, leaving one to the others, so a throttled stream with slow
+// flushes cannot starve the healthy ones. Writes to a buffer block while it already has a
+// batch waiting for a worker, for up to the write timeout of WithBufferWriteTimeout.
+type Manager struct {
+	factory *Factory
+	size    int
+	workers sync.WaitGroup
+	stop    chan struct{}
+	stopped chan struct{}
+
+	// queueMu guards the batches of the buffers and the run queue.
+	queueMu sync.Mutex
+	ready   *sync.Cond
+	// queue holds the buffers with batches waiting or running, in the order they are served.
+	queue    []*managedBuffer
+	next     int
+	stopping bool
+
+	mu      sync.Mutex
+	buffers map[*managedBuffer]struct{}
+	writers []*Writer
+	closed  bool
+}
+
+// NewManager creates a new Manager running workers flush workers.
+// opts are applied to every writer of the manager. Unless WithKinesisClient is given,
+// a client is created once from the default AWS config.
+func NewManager(ctx context.Context, workers int, opts ...WriterConfigOption) (*Manager, error) {
+	factory, err := NewFactory(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	m := &Manager{
+		factory: factory,
+		size:    max(workers, 1),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+		buffers: make(map[*managedBuffer]struct{}),
+	}
+	m.ready = sync.NewCond(&m.queueMu)
+	for range m.size {
+		m.workers.Add(1)
+		go func() {
+			defer m.workers.Done()
+			m.work()
+		}()
+	}
+	go m.schedule()
+	return m, nil
+}
+
+// New creates a new Writer for streamARN. overrides are applied after the default options.
+// A Buffer set with WithBuffer is replaced by the shared one.
+func (m *Manager) New(ctx context.Context, streamARN string, overrides ...WriterConfigOption) (*Writer, error) {
+	return New(ctx, streamARN, slices.Concat(m.factory.opts, overrides, []WriterConfigOption{withManager(m)})...)
+}
+
+// withManager makes the writer flush through m.
+func withManager(m *Manager) WriterConfigOption {
+	return func(c *writerConfig) {
+		c.manager = m
+	}
+}
+
+// newBuffer creates a buffer flushed by the workers of m.
+func (m *Manager) newBuffer(flush func([]Record) error, conf bufferConfig) Buffer {
+	b := &managedBuffer{manager: m, flush: flush, conf: conf, lastFlush: time.Now()}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.buffers[b] = struct{}{}
+	return b
+}
+
+// add registers w to be closed with m.
+func (m *Manager) add(w *Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return ErrManagerClosed
+	}
+	m.writers = append(m.writers, w)
+	return nil
+}
+
+// remove forgets w once it is closed.
+func (m *Manager) remove(w *Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if i := slices.Index(m.writers, w); i >= 0 {
+		m.writers = slices.Delete(m.writers, i, i+1)
+	}
+}
+
+// Close closes the writers of the manager and stops its scheduler and workers.
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return ErrManagerClosed
+	}
+	m.closed = true
+	writers := slices.Clone(m.writers)
+	m.mu.Unlock()
+
+	var errs []error
+	for _, w := range writers {
+		if err := w.Close(); err != nil && !errors.Is(err, ErrWriterClosed) {
+			errs = append(errs, fmt.Errorf("failed to close writer for %s: %w", w.StreamARN(), err))
+		}
+	}
+	close(m.stop)
+	<-m.stopped
+	m.queueMu.Lock()
+	m.stopping = true
+	m.ready.Broadcast()
+	m.queueMu.Unlock()
+	m.workers.Wait()
+	return errors.Join(errs...)
+}
+
+// schedule flushes the buffers whose flush interval has elapsed.
+func (m *Manager) schedule() {
+	defer close(m.stopped)
+	ticker := time.NewTicker(managerTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case now := <-ticker.C:
+			m.mu.Lock()
+			due := make([]*managedBuffer, 0, len(m.buffers))
+			for b := range m.buffers {
+				due = append(due, b)
+			}
+			m.mu.Unlock()
+			for _, b := range due {
+				b.flushIfDue(now)
+			}
+		}
+	}
+}
+
+// work runs the batches picked by take until the manager stops.
+func (m *Manager) work() {
+	m.queueMu.Lock()
+	defer m.queueMu.Unlock()
+	for {
+		b, batch := m.take()
+		if b == nil {
+			if m.stopping {
+				return
+			}
+			m.ready.Wait()
+			continue
+		}
+		m.queueMu.Unlock()
+		if err := b.flush(batch); err != nil {
+			b.conf.errorHandler(err, recordsData(batch))
+		}
+		b.pending.Done()
+		m.queueMu.Lock()
+		b.running--
+		if b.running == 0 && len(b.batches) == 0 {
+			m.dequeue(b)
+		}
+		m.ready.Broadcast()
+	}
+}
+
+// take picks the next batch in turn from a buffer running less than its fair share of the
+// workers. m.queueMu must be held.
+func (m *Manager) take() (*managedBuffer, []Record) {
+	share := max(1, m.size/max(len(m.queue), 1))
+	if len(m.queue) > 1 && m.size > 1 {
+		share = min(share, m.size-1)
+	}
+	for i := range len(m.queue) {
+		b := m.queue[(m.next+i)%len(m.queue)]
+		if len(b.batches) == 0 || b.running >= share {
+			continue
+		}
+		m.next = (m.next + i + 1) % len(m.queue)
+		batch := b.batches[0]
+		b.batches = b.batches[1:]
+		b.running++
+		m.ready.Broadcast()
+		return b, batch
+	}
+	return nil, nil
+}
+
+// dequeue removes b from the run queue. m.queueMu must be held.
+func (m *Manager) dequeue(b *managedBuffer) {
+	i := slices.Index(m.queue, b)
+	if i < 0 {
+		return
+	}
+	m.queue = slices.Delete(m.queue, i, i+1)
+	if m.next > i {
+		m.next--
+	}
+	if len(m.queue) > 0 {
+		m.next %= len(m.queue)
+	} else {
+		m.next = 0
+	}
+}
+
+func (m *Manager) unregister(b *managedBuffer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.buffers, b)
+}
+
+// managedBuffer is the Buffer of a managed writer. Batches are flushed by the workers
+// of the manager when the record window fills up or the flush interval elapses.
+type managedBuffer struct {
+	manager *Manager
+	flush   func([]Record) error
+	conf    bufferConfig
+	pending sync.WaitGroup
+
+	mu        sync.Mutex
+	records   []Record
+	lastFlush time.Time
+	closed    bool
+
+	// batches and running are guarded by manager.queueMu.
+	batches [][]Record
+	running int
+}
+
+func (b *managedBuffer) Write(records ...Record) (int, error) {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return 0, ErrWriterClosed
+	}
+	b.records = append(b.records, records...)
+	var batch []Record
+	if len(b.records) >= int(b.conf.recordWindow) {
+		batch = b.take()
+	}
+	b.mu.Unlock()
+	if err := b.submit(batch, b.conf.writeTimeout); err != nil {
+		// records were not accepted, but the records before them stay buffered.
+		b.restore(batch[:len(batch)-len(records)])
+		return 0, err
+	}
+	return len(records), nil
+}
+
+func (b *managedBuffer) Flush() error {
+	b.mu.Lock()
+	batch := b.take()
+	b.mu.Unlock()
+	if err := b.submit(batch, b.conf.writeTimeout); err != nil {
+		b.restore(batch)
+		return err
+	}
+	return nil
+}
+
+// Close flushes the remaining records synchronously after the submitted batches.
+func (b *managedBuffer) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return ErrWriterClosed
+	}
+	b.closed = true
+	batch := b.take()
+	b.mu.Unlock()
+	b.manager.unregister(b)
+	b.pending.Wait()
+	if len(batch) == 0 {
+		return nil
+	}
+	return b.flush(batch)
+}
+
+func (b *managedBuffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.records)
+}
+
+// flushIfDue submits the buffered records if the flush interval has elapsed. It does not wait
+// for a queued batch of b, so that a stuck stream does not hold up the other buffers.
+func (b *managedBuffer) flushIfDue(now time.Time) {
+	b.mu.Lock()
+	var batch []Record
+	if b.conf.flushInterval > 0 && now.Sub(b.lastFlush) >= b.conf.flushInterval {
+		batch = b.take()
+	}
+	b.mu.Unlock()
+	if err := b.submit(batch, noWait); err != nil {
+		b.restore(batch)
+	}
+}
+
+// take removes the buffered records. b.mu must be held.
+func (b *managedBuffer) take() []Record {
+	b.lastFlush = time.Now()
+	batch := b.records
+	b.records = nil
+	return batch
+}
+
+// restore puts back the records of a batch that could not be submitted, before the newer ones.
+func (b *managedBuffer) restore(batch []Record) {
+	if len(batch) == 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.records = append(batch[:len(batch):len(batch)], b.records...)
+}
+
+// noWait makes submit fail at once while another batch is queued.
+const noWait = -1
+
+// submit queues batch for the workers, waiting while another batch of b is queued for up to
+// timeout, or without limit if it is zero. It returns buffer.ErrWriteTimeout past the timeout.
+func (b *managedBuffer) submit(batch []Record, timeout time.Duration) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	m := b.manager
+	m.queueMu.Lock()
+	defer m.queueMu.Unlock()
+	var expired bool
+	if timeout > 0 && len(b.batches) > 0 {
+		timer := time.AfterFunc(timeout, func() {
+			m.queueMu.Lock()
+			defer m.queueMu.Unlock()
+			expired = true
+			m.ready.Broadcast()
+		})
+		defer timer.Stop()
+	}
+	for len(b.batches) > 0 {
+		if expired || timeout < 0 {
+			return buffer.ErrWriteTimeout
+		}
+		m.ready.Wait()
+	}
+	if b.running == 0 {
+		m.queue = append(m.queue, b)
+	}
+	b.batches = append(b.batches, batch)
+	b.pending.Add(1)
+	m.ready.Broadcast()
+	return nil
+}
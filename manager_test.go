@@ -0,0 +1,143 @@
+package kinesiswriter_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis"
+	kinesiswriter "github.com/mackee/go-kinesis-writer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	buffer "github.com/woorui/async-buffer"
+)
+
+type lockedKinesisClient struct {
+	mu sync.Mutex
+	successKinesisClient
+}
+
+func (c *lockedKinesisClient) PutRecords(ctx context.Context, params *kinesis.PutRecordsInput, optFns ...func(*kinesis.Options)) (*kinesis.PutRecordsOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.successKinesisClient.PutRecords(ctx, params, optFns...)
+}
+
+func (c *lockedKinesisClient) Inputs() []*kinesis.PutRecordsInput {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.successKinesisClient.Inputs()
+}
+
+// blockingKinesisClient holds the PutRecords calls to a stream until release is closed.
+type blockingKinesisClient struct {
+	lockedKinesisClient
+	blocked string
+	release chan struct{}
+}
+
+func (c *blockingKinesisClient) PutRecords(ctx context.Context, params *kinesis.PutRecordsInput, optFns ...func(*kinesis.Options)) (*kinesis.PutRecordsOutput, error) {
+	if aws.ToString(params.StreamARN) == c.blocked {
+		<-c.release
+	}
+	return c.lockedKinesisClient.PutRecords(ctx, params, optFns...)
+}
+
+func TestManagerFairness(t *testing.T) {
+	ctx := context.Background()
+	client := &blockingKinesisClient{blocked: stream1ARN, release: make(chan struct{})}
+	manager, err := kinesiswriter.NewManager(ctx, 2,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithBufferRecordWindow(2),
+		kinesiswriter.WithBufferFlushInterval(time.Hour),
+	)
+	require.NoError(t, err)
+	slow, err := manager.New(ctx, stream1ARN)
+	require.NoError(t, err)
+	healthy, err := manager.New(ctx, stream2ARN)
+	require.NoError(t, err)
+
+	// the slow stream runs on one worker and queues a second batch, leaving a worker to the others.
+	_, err = slow.Write([]byte("record1\nrecord2\n"))
+	require.NoError(t, err)
+	_, err = slow.Write([]byte("record3\nrecord4\n"))
+	require.NoError(t, err)
+	_, err = healthy.Write([]byte("record5\nrecord6\n"))
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool { return len(client.Inputs()) == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, stream2ARN, aws.ToString(client.Inputs()[0].StreamARN))
+
+	close(client.release)
+	require.NoError(t, manager.Close())
+	assert.Len(t, client.Inputs(), 3)
+}
+
+func TestManagerWriteTimeout(t *testing.T) {
+	ctx := context.Background()
+	client := &blockingKinesisClient{blocked: stream1ARN, release: make(chan struct{})}
+	manager, err := kinesiswriter.NewManager(ctx, 1,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithBufferRecordWindow(2),
+		kinesiswriter.WithBufferFlushInterval(time.Hour),
+		kinesiswriter.WithBufferWriteTimeout(50*time.Millisecond),
+	)
+	require.NoError(t, err)
+	slow, err := manager.New(ctx, stream1ARN)
+	require.NoError(t, err)
+
+	// one batch runs and one is queued, so the next batch cannot be submitted.
+	_, err = slow.Write([]byte("record1\nrecord2\nrecord3\nrecord4\n"))
+	require.NoError(t, err)
+	_, err = slow.Write([]byte("record5\nrecord6\n"))
+	assert.ErrorIs(t, err, buffer.ErrWriteTimeout)
+	assert.Equal(t, 1, slow.Len(), "the record before the timed out one stays buffered")
+
+	close(client.release)
+	require.NoError(t, manager.Close())
+	var sent []string
+	for _, input := range client.Inputs() {
+		for _, entry := range input.Records {
+			sent = append(sent, string(entry.Data))
+		}
+	}
+	assert.ElementsMatch(t, []string{"record1", "record2", "record3", "record4", "record5"}, sent)
+}
+
+func TestManager(t *testing.T) {
+	ctx := context.Background()
+	client := &lockedKinesisClient{}
+	manager, err := kinesiswriter.NewManager(ctx, 2,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithBufferRecordWindow(2),
+		kinesiswriter.WithBufferFlushInterval(100*time.Millisecond),
+	)
+	require.NoError(t, err)
+
+	w1, err := manager.New(ctx, stream1ARN)
+	require.NoError(t, err)
+	w2, err := manager.New(ctx, stream2ARN, kinesiswriter.WithBufferFlushInterval(time.Hour))
+	require.NoError(t, err)
+
+	// the window of w1 fills up.
+	_, err = w1.Write([]byte("record1\nrecord2\n"))
+	require.NoError(t, err)
+	// the interval of w1 elapses, and w2 waits for Close.
+	_, err = w1.Write([]byte("record3\n"))
+	require.NoError(t, err)
+	_, err = w2.Write([]byte("record4\n"))
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool { return len(client.Inputs()) == 2 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, w2.Len())
+
+	require.NoError(t, manager.Close())
+	inputs := client.Inputs()
+	require.Len(t, inputs, 3)
+	assert.Equal(t, stream2ARN, aws.ToString(inputs[2].StreamARN))
+	assert.Len(t, inputs[2].Records, 1)
+	_, err = w1.Write([]byte("record5\n"))
+	assert.ErrorIs(t, err, kinesiswriter.ErrWriterClosed)
+	_, err = manager.New(ctx, stream1ARN)
+	assert.ErrorIs(t, err, kinesiswriter.ErrManagerClosed)
+}
@@ -0,0 +1,103 @@
+package kinesiswriter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"hash/crc32"
+	"log"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis/types"
+)
+
+// manifestPrefix starts every manifest record.
+const manifestPrefix = `{"kinesiswriter_manifest":`
+
+// manifestTimeout bounds sending a manifest record.
+const manifestTimeout = 10 * time.Second
+
+// BatchManifest describes a flushed batch of records. Records do not carry their batch, so
+// consumers verify totals instead: over the manifests of a period, the sums of Count, ByteSize
+// and Checksum match those over the records received, with the checksum sum wrapping around.
+type BatchManifest struct {
+	// Batch numbers the batches of a stream from 1, so that consumers can spot missing manifests.
+	Batch uint64 `json:"kinesiswriter_manifest"`
+	// Count is the number of records accepted by Kinesis, and Failed the number of the others.
+	Count  int `json:"count"`
+	Failed int `json:"failed"`
+	// ByteSize is the data size of the accepted records.
+	ByteSize int `json:"byte_size"`
+	// Checksum is the sum of the CRC-32 (IEEE) checksums of the data of the accepted records,
+	// which does not depend on the order the records are read in.
+	Checksum uint32 `json:"checksum"`
+	// WriterID is the ID of the writer set with WithWriterID, if any.
+	WriterID string `json:"writer_id,omitempty"`
+}
+
+// ParseBatchManifest decodes record if it is a manifest record.
+func ParseBatchManifest(record []byte) (BatchManifest, bool) {
+	if !bytes.HasPrefix(record, []byte(manifestPrefix)) {
+		return BatchManifest{}, false
+	}
+	var m BatchManifest
+	if err := json.Unmarshal(record, &m); err != nil {
+		return BatchManifest{}, false
+	}
+	return m, true
+}
+
+// batchManifests numbers the flushed batches of a flusher and sends their manifests.
+type batchManifests struct {
+	streamARN string
+	batches   atomic.Uint64
+}
+
+// manifest describes records after their flush failed with err.
+func (m *batchManifests) manifest(records []Record, err error) BatchManifest {
+	bm := BatchManifest{
+		Batch: m.batches.Add(1),
+		Count: len(records),
+	}
+	for _, r := range records {
+		bm.ByteSize += len(r.Data)
+		bm.Checksum += crc32.ChecksumIEEE(r.Data)
+	}
+	var flushErr *FlushError
+	if errors.As(err, &flushErr) {
+		for _, data := range flushErr.FailedRecords {
+			bm.Failed++
+			bm.ByteSize -= len(data)
+			bm.Checksum -= crc32.ChecksumIEEE(data)
+		}
+		bm.Count -= bm.Failed
+	}
+	return bm
+}
+
+// sendManifest puts the manifest of records to the manifest stream with a single attempt.
+func (f *flusher) sendManifest(records []Record, err error) {
+	bm := f.manifests.manifest(records, err)
+	bm.WriterID = f.writerID
+	data, _ := json.Marshal(bm)
+	ctx, cancel := context.WithTimeout(context.Background(), manifestTimeout)
+	defer cancel()
+	ret, err := f.putRecords(ctx, &kinesis.PutRecordsInput{
+		Records: []types.PutRecordsRequestEntry{{
+			Data:         data,
+			PartitionKey: aws.String(strconv.FormatUint(bm.Batch, 10)),
+		}},
+		StreamARN: aws.String(f.manifests.streamARN),
+	})
+	if err == nil && aws.ToInt32(ret.FailedRecordCount) > 0 {
+		err = errors.New(aws.ToString(ret.Records[0].ErrorCode))
+	}
+	if err != nil {
+		log.Printf("failed to put manifest of batch %d: %s", bm.Batch, err)
+	}
+}
@@ -0,0 +1,52 @@
+package kinesiswriter_test
+
+import (
+	"context"
+	"hash/crc32"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	kinesiswriter "github.com/mackee/go-kinesis-writer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterBatchManifest(t *testing.T) {
+	client := &partialFailedKinesisClient{}
+	writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithImmediateFlush(),
+		kinesiswriter.WithNoRetry(),
+		kinesiswriter.WithBatchManifest(stream2ARN),
+	)
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("record1\nrecord2\nrecord3"))
+	require.Error(t, err)
+	_, err = writer.Write([]byte("record4"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	inputs := client.Inputs()
+	require.Len(t, inputs, 4)
+	var manifests []kinesiswriter.BatchManifest
+	for _, input := range []int{1, 3} {
+		assert.Equal(t, stream2ARN, aws.ToString(inputs[input].StreamARN))
+		require.Len(t, inputs[input].Records, 1)
+		m, ok := kinesiswriter.ParseBatchManifest(inputs[input].Records[0].Data)
+		require.True(t, ok)
+		manifests = append(manifests, m)
+	}
+	checksum := crc32.ChecksumIEEE([]byte("record1")) + crc32.ChecksumIEEE([]byte("record3"))
+	assert.Equal(t, []kinesiswriter.BatchManifest{
+		{Batch: 1, Count: 2, Failed: 1, ByteSize: 14, Checksum: checksum},
+		{Batch: 2, Count: 1, ByteSize: 7, Checksum: crc32.ChecksumIEEE([]byte("record4"))},
+	}, manifests)
+
+	_, ok := kinesiswriter.ParseBatchManifest([]byte("record1"))
+	assert.False(t, ok)
+	_, err = kinesiswriter.New(context.Background(), testStreamARN,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithBatchManifest("stream"),
+	)
+	assert.Error(t, err)
+}
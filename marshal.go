@@ -0,0 +1,28 @@
+package kinesiswriter
+
+import (
+	"bytes"
+	"encoding"
+	"fmt"
+)
+
+// WriteMarshaler writes v marshaled with MarshalBinary as a single record.
+// The record goes through transforms and validation like the records of Write.
+func (w *Writer) WriteMarshaler(v encoding.BinaryMarshaler) error {
+	data, err := v.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("failed to marshal record: %w", err)
+	}
+	// v may reuse its buffer for the next record.
+	return w.writeRecords([][]byte{bytes.Clone(data)}, w.config.priorityFunc)
+}
+
+// WriteTextMarshaler writes v marshaled with MarshalText as a single record.
+// The record goes through transforms and validation like the records of Write.
+func (w *Writer) WriteTextMarshaler(v encoding.TextMarshaler) error {
+	data, err := v.MarshalText()
+	if err != nil {
+		return fmt.Errorf("failed to marshal record: %w", err)
+	}
+	return w.writeRecords([][]byte{bytes.Clone(data)}, w.config.priorityFunc)
+}
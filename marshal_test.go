@@ -0,0 +1,206 @@
+package kinesiswriter_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"testing"
+	"time"
+
+	kinesiswriter "github.com/mackee/go-kinesis-writer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failingMarshaler struct{}
+
+func (failingMarshaler) MarshalBinary() ([]byte, error) {
+	return nil, errors.New("broken")
+}
+
+func TestWriterWriteMarshaler(t *testing.T) {
+	client := &successKinesisClient{}
+	writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithImmediateFlush(),
+	)
+	require.NoError(t, err)
+	defer writer.Close()
+
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	require.NoError(t, writer.WriteTextMarshaler(at))
+	require.NoError(t, writer.WriteMarshaler(netip.MustParseAddr("192.0.2.1")))
+	require.Len(t, client.Inputs(), 2)
+	assert.Equal(t, []byte("2024-01-02T03:04:05Z"), client.Inputs()[0].Records[0].Data)
+	assert.Equal(t, []byte{192, 0, 2, 1}, client.Inputs()[1].Records[0].Data)
+
+	assert.ErrorContains(t, writer.WriteMarshaler(failingMarshaler{}), "failed to marshal record: broken")
+}
+
+func TestWriterWriteValue(t *testing.T) {
+	type event struct {
+		Name  string
+		Count int
+	}
+	tests := []struct {
+		name   string
+		codec  kinesiswriter.Codec
+		decode func(data []byte) (event, error)
+	}{
+		{
+			name: "json",
+			decode: func(data []byte) (event, error) {
+				var e event
+				err := json.Unmarshal(data, &e)
+				return e, err
+			},
+		},
+		{
+			name:  "gob",
+			codec: kinesiswriter.GobCodec,
+			decode: func(data []byte) (event, error) {
+				var e event
+				err := gob.NewDecoder(bytes.NewReader(data)).Decode(&e)
+				return e, err
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &successKinesisClient{}
+			opts := []kinesiswriter.WriterConfigOption{
+				kinesiswriter.WithKinesisClient(client),
+				kinesiswriter.WithImmediateFlush(),
+			}
+			if tt.codec != nil {
+				opts = append(opts, kinesiswriter.WithCodec(tt.codec))
+			}
+			writer, err := kinesiswriter.New(context.Background(), testStreamARN, opts...)
+			require.NoError(t, err)
+			defer writer.Close()
+
+			want := event{Name: "click", Count: 2}
+			require.NoError(t, writer.WriteValue(want))
+			require.Len(t, client.Inputs(), 1)
+			got, err := tt.decode(client.Inputs()[0].Records[0].Data)
+			require.NoError(t, err)
+			assert.Equal(t, want, got)
+		})
+	}
+}
+
+func TestWriterWriteValueReusingBuffer(t *testing.T) {
+	client := &successKinesisClient{}
+	buf := make([]byte, len("record1"))
+	writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithManualFlush(),
+		kinesiswriter.WithCodec(kinesiswriter.CodecFunc(func(v any) ([]byte, error) {
+			copy(buf, v.(string))
+			return buf, nil
+		})),
+	)
+	require.NoError(t, err)
+	require.NoError(t, writer.WriteValue("record1"))
+	require.NoError(t, writer.WriteValue("record2"))
+	require.NoError(t, writer.Close())
+
+	require.Len(t, client.Inputs(), 1)
+	var records []string
+	for _, entry := range client.Inputs()[0].Records {
+		records = append(records, string(entry.Data))
+	}
+	assert.Equal(t, []string{"record1", "record2"}, records)
+}
+
+func TestLookupCodec(t *testing.T) {
+	codec, ok := kinesiswriter.LookupCodec("json")
+	require.True(t, ok)
+	data, err := codec.Encode(map[string]int{"a": 1})
+	require.NoError(t, err)
+	assert.Equal(t, `{"a":1}`, string(data))
+
+	kinesiswriter.RegisterCodec("upper", kinesiswriter.CodecFunc(func(v any) ([]byte, error) {
+		return bytes.ToUpper([]byte(fmt.Sprint(v))), nil
+	}))
+	codec, ok = kinesiswriter.LookupCodec("upper")
+	require.True(t, ok)
+	data, err = codec.Encode("abc")
+	require.NoError(t, err)
+	assert.Equal(t, "ABC", string(data))
+
+	_, ok = kinesiswriter.LookupCodec("msgpack")
+	assert.False(t, ok)
+}
+
+// reusingMarshaler marshals into the same buffer every time.
+type reusingMarshaler struct {
+	buf []byte
+}
+
+func (m *reusingMarshaler) MarshalBinary() ([]byte, error) {
+	return m.buf, nil
+}
+
+func TestWriterMarshalerReusingBuffer(t *testing.T) {
+	client := &successKinesisClient{}
+	writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithManualFlush(),
+	)
+	require.NoError(t, err)
+	m := &reusingMarshaler{buf: []byte("record1")}
+	require.NoError(t, writer.WriteMarshaler(m))
+	copy(m.buf, "record2")
+	require.NoError(t, writer.WriteMarshaler(m))
+	require.NoError(t, writer.Close())
+
+	require.Len(t, client.Inputs(), 1)
+	var records []string
+	for _, entry := range client.Inputs()[0].Records {
+		records = append(records, string(entry.Data))
+	}
+	assert.Equal(t, []string{"record1", "record2"}, records)
+}
+
+func TestWriterMutationGuard(t *testing.T) {
+	write := func(t *testing.T, report kinesiswriter.RejectHandler, opts ...kinesiswriter.WriterConfigOption) *kinesiswriter.Writer {
+		t.Helper()
+		// the transform reuses its output buffer.
+		buf := make([]byte, len("record1"))
+		writer, err := kinesiswriter.New(context.Background(), testStreamARN, append([]kinesiswriter.WriterConfigOption{
+			kinesiswriter.WithKinesisClient(&successKinesisClient{}),
+			kinesiswriter.WithManualFlush(),
+			kinesiswriter.WithTransform(func(record []byte) ([]byte, error) {
+				copy(buf, record)
+				return buf, nil
+			}),
+			kinesiswriter.WithMutationGuard(report),
+		}, opts...)...)
+		require.NoError(t, err)
+		_, err = writer.Write([]byte("record1\n"))
+		require.NoError(t, err)
+		_, err = writer.Write([]byte("record2\n"))
+		require.NoError(t, err)
+		return writer
+	}
+
+	var mutated [][]byte
+	writer := write(t, func(err error, record []byte) {
+		assert.ErrorIs(t, err, kinesiswriter.ErrRecordMutated)
+		mutated = append(mutated, record)
+	})
+	require.NoError(t, writer.Sync())
+	assert.Equal(t, [][]byte{[]byte("record2")}, mutated)
+	require.NoError(t, writer.Close())
+
+	writer = write(t, nil)
+	assert.Panics(t, func() { _ = writer.Sync() })
+
+	writer = write(t, nil, kinesiswriter.WithRedactor(kinesiswriter.RedactOmit))
+	assert.PanicsWithValue(t, kinesiswriter.ErrRecordMutated.Error()+": <7 bytes>", func() { _ = writer.Sync() })
+}
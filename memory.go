@@ -0,0 +1,45 @@
+package kinesiswriter
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// memoryGaugeInterval is how long a reading of the memory gauge is reused.
+const memoryGaugeInterval = 100 * time.Millisecond
+
+// memoryShedder tells whether the process memory is over the limit.
+type memoryShedder struct {
+	limit uint64
+	gauge func() uint64
+
+	mu     sync.Mutex
+	readAt time.Time
+	over   bool
+}
+
+func newMemoryShedder(limit uint64, gauge func() uint64) *memoryShedder {
+	if gauge == nil {
+		gauge = heapAlloc
+	}
+	return &memoryShedder{limit: limit, gauge: gauge}
+}
+
+// pressured reports whether the memory in use is over the limit,
+// reading the gauge at most once per memoryGaugeInterval.
+func (s *memoryShedder) pressured() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now := time.Now(); now.Sub(s.readAt) >= memoryGaugeInterval {
+		s.over = s.gauge() >= s.limit
+		s.readAt = now
+	}
+	return s.over
+}
+
+func heapAlloc() uint64 {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return m.HeapAlloc
+}
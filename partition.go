@@ -0,0 +1,301 @@
+package kinesiswriter
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"log"
+	"math/big"
+	"math/rand"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+const (
+	defaultShardRefreshInterval = time.Minute
+	// staleShardRefreshInterval is how often a stale shard map is refreshed while refreshing fails.
+	staleShardRefreshInterval = 5 * time.Second
+)
+
+// Partitioner decides the partition key and the optional explicit hash key of a record.
+// Implementations must be safe for concurrent use.
+type Partitioner interface {
+	Key(record []byte) (partitionKey string, explicitHashKey *string)
+}
+
+// PartitionerFunc adapts a function to a Partitioner.
+type PartitionerFunc func(record []byte) (partitionKey string, explicitHashKey *string)
+
+// Key calls f(record).
+func (f PartitionerFunc) Key(record []byte) (string, *string) {
+	return f(record)
+}
+
+// RandomPartitioner returns the default Partitioner, which spreads records with random partition keys.
+func RandomPartitioner() Partitioner {
+	return randomPartitioner{}
+}
+
+// StaticPartitioner returns a Partitioner sending every record with partitionKey,
+// so that all records go to one shard in order.
+func StaticPartitioner(partitionKey string) Partitioner {
+	return PartitionerFunc(func([]byte) (string, *string) {
+		return partitionKey, nil
+	})
+}
+
+// ContentPartitioner returns a Partitioner using the key derived from each record as its
+// partition key, so that records with the same key stay in order on one shard.
+// Keys longer than 256 characters are replaced by their MD5 digest.
+// Records with an empty key are partitioned by fallback.
+func ContentPartitioner(key func(record []byte) string, fallback Partitioner) Partitioner {
+	return contentPartitioner{key: key, fallback: fallback}
+}
+
+type contentPartitioner struct {
+	key      func(record []byte) string
+	fallback Partitioner
+}
+
+func (p contentPartitioner) Key(record []byte) (string, *string) {
+	key := p.key(record)
+	if key == "" {
+		return p.fallback.Key(record)
+	}
+	return shortPartitionKey(key), nil
+}
+
+// shortPartitionKey replaces keys over the partition key size limit by their MD5 digest.
+func shortPartitionKey(key string) string {
+	if len(key) <= maxPartitionKeySize {
+		return key
+	}
+	sum := md5.Sum([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// StickyPartitioner returns a Partitioner reusing each key of keys for n consecutive records,
+// so that records written together land on the same shard.
+func StickyPartitioner(n int, keys Partitioner) Partitioner {
+	return &stickyPartitioner{n: max(n, 1), keys: keys}
+}
+
+type stickyPartitioner struct {
+	n    int
+	keys Partitioner
+
+	mu      sync.Mutex
+	used    int
+	key     string
+	hashKey *string
+}
+
+func (p *stickyPartitioner) Key(record []byte) (string, *string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.used == 0 {
+		p.key, p.hashKey = p.keys.Key(record)
+	}
+	p.used = (p.used + 1) % p.n
+	return p.key, p.hashKey
+}
+
+// ConsistentHashPartitioner returns a Partitioner mapping the key derived from each record to one
+// of buckets equal slices of the hash key space with jump consistent hashing, and sending the
+// record with the explicit hash key in the middle of the slice. With buckets set to the number of
+// uniformly split shards, each key stays on one shard, and raising buckets along with a uniform
+// reshard moves only the keys that land on the new shard. Records with an empty key are
+// partitioned by fallback.
+func ConsistentHashPartitioner(key func(record []byte) string, buckets int, fallback Partitioner) Partitioner {
+	buckets = max(buckets, 1)
+	width := new(big.Int).Div(new(big.Int).Lsh(big.NewInt(1), 128), big.NewInt(int64(buckets)))
+	hashKeys := make([]string, buckets)
+	for i := range hashKeys {
+		k := new(big.Int).Mul(width, big.NewInt(int64(i)))
+		hashKeys[i] = k.Add(k, new(big.Int).Rsh(width, 1)).String()
+	}
+	return PartitionerFunc(func(record []byte) (string, *string) {
+		k := key(record)
+		if k == "" {
+			return fallback.Key(record)
+		}
+		sum := md5.Sum([]byte(k))
+		bucket := jumpHash(binary.BigEndian.Uint64(sum[:8]), buckets)
+		return shortPartitionKey(k), aws.String(hashKeys[bucket])
+	})
+}
+
+// jumpHash is the jump consistent hash of Lamping and Veach.
+func jumpHash(key uint64, buckets int) int {
+	var b, j int64 = -1, 0
+	for j < int64(buckets) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
+
+// randomPartitioner spreads records with random partition keys.
+type randomPartitioner struct{}
+
+func (randomPartitioner) Key([]byte) (string, *string) {
+	return strconv.Itoa(rand.Int()), nil
+}
+
+// seededPartitioner generates partition keys from a seeded random source,
+// so that the keys are reproducible.
+type seededPartitioner struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+func newSeededPartitioner(seed int64) *seededPartitioner {
+	return &seededPartitioner{rnd: rand.New(rand.NewSource(seed))}
+}
+
+func (p *seededPartitioner) Key([]byte) (string, *string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return strconv.Itoa(p.rnd.Int()), nil
+}
+
+// sequentialPartitioner generates partition keys from a counter starting at 0.
+type sequentialPartitioner struct {
+	next atomic.Uint64
+}
+
+func (p *sequentialPartitioner) Key([]byte) (string, *string) {
+	return strconv.FormatUint(p.next.Add(1)-1, 10), nil
+}
+
+// roundRobinPartitioner cycles explicit hash keys over the open shards of a stream
+// so that every shard receives the same number of records.
+// When a reshard makes the shard map stale, explicit hash keys are paused until it is refreshed.
+// The shard map is refreshed in the background, serving the known one meanwhile.
+type roundRobinPartitioner struct {
+	keys            Partitioner
+	lister          KinesisShardLister
+	streamARN       string
+	refreshInterval time.Duration
+	next            atomic.Uint64
+
+	mu          sync.Mutex
+	ranges      ShardHashRanges
+	refreshedAt time.Time
+	// stale is set when the stream is resharding or reports shards missing from ranges.
+	stale bool
+	// refreshed is closed once the running refresh, if any, is done.
+	refreshed chan struct{}
+	loaded    bool
+	// invalidations counts invalidate calls, so that a refresh started before one is not trusted.
+	invalidations uint64
+}
+
+func (p *roundRobinPartitioner) Key(record []byte) (string, *string) {
+	key, _ := p.keys.Key(record)
+	ranges := p.currentRanges()
+	if len(ranges) == 0 {
+		return key, nil
+	}
+	i := p.next.Add(1) % uint64(len(ranges))
+	return key, aws.String(ranges.ForIndex(int(i)).StartingHashKey.String())
+}
+
+// currentRanges returns the hash key ranges of the open shards, starting a refresh once they
+// are older than the refresh interval so that reshards are followed. Only the first call
+// waits for the shards to be listed, as there are no ranges to serve before.
+func (p *roundRobinPartitioner) currentRanges() ShardHashRanges {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	interval := p.refreshInterval
+	if p.stale {
+		interval = staleShardRefreshInterval
+	}
+	if p.refreshed == nil && time.Since(p.refreshedAt) >= interval {
+		p.refreshedAt = time.Now()
+		p.refreshed = make(chan struct{})
+		go p.refresh(p.refreshed, p.invalidations)
+	}
+	if !p.loaded {
+		refreshed := p.refreshed
+		p.mu.Unlock()
+		<-refreshed
+		p.mu.Lock()
+	}
+	if p.stale {
+		return nil
+	}
+	return p.ranges
+}
+
+// refresh lists the shards and closes refreshed once the shard map is updated.
+func (p *roundRobinPartitioner) refresh(refreshed chan struct{}, invalidations uint64) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ranges, err := ListShardHashRanges(ctx, p.lister, p.streamARN)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	defer close(refreshed)
+	p.refreshed, p.loaded = nil, true
+	if invalidations != p.invalidations {
+		// the shards may have changed after they were listed.
+		return
+	}
+	if err != nil {
+		if p.stale {
+			log.Printf("failed to refresh shards while resharding, explicit hash keys stay paused: %s", err)
+			return
+		}
+		log.Printf("failed to refresh shards, keep using %d known shards: %s", len(p.ranges), err)
+		return
+	}
+	if p.stale {
+		log.Printf("shards refreshed after resharding, resuming explicit hash keys over %d shards", len(ranges))
+	}
+	p.ranges, p.stale = ranges, false
+}
+
+// shardCount returns the number of known open shards, or 0 if they were never listed.
+func (p *roundRobinPartitioner) shardCount() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.ranges)
+}
+
+// invalidate marks the shard map stale, so that it is refreshed before the next record
+// and explicit hash keys are paused until the refresh succeeds.
+func (p *roundRobinPartitioner) invalidate() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.stale {
+		log.Printf("shard map of %s is stale, pausing explicit hash keys until it is refreshed", p.streamARN)
+	}
+	p.stale = true
+	p.refreshedAt = time.Time{}
+	p.invalidations++
+}
+
+// observe invalidates the shard map when records were accepted by shards it does not know.
+func (p *roundRobinPartitioner) observe(shardIDs []string) {
+	p.mu.Lock()
+	known := p.ranges
+	stale := p.stale || len(p.ranges) == 0
+	p.mu.Unlock()
+	if stale {
+		return
+	}
+	for _, id := range shardIDs {
+		if !slices.ContainsFunc(known, func(r ShardHashRange) bool { return r.ShardID == id }) {
+			p.invalidate()
+			return
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package kinesiswriter
+
+import (
+	"sync"
+	"time"
+)
+
+// periodic runs a task on a ticker until stopped.
+type periodic struct {
+	task    func()
+	final   bool
+	stopped chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+// startPeriodic runs task every interval. If final is set, task runs once more when stopped.
+func startPeriodic(interval time.Duration, task func(), final bool) *periodic {
+	p := &periodic{
+		task:    task,
+		final:   final,
+		stopped: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go p.run(interval)
+	return p
+}
+
+func (p *periodic) run(interval time.Duration) {
+	defer close(p.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.task()
+		case <-p.stopped:
+			return
+		}
+	}
+}
+
+// stop stops the ticker and waits for a running task.
+func (p *periodic) stop() {
+	p.once.Do(func() {
+		close(p.stopped)
+		<-p.done
+		if p.final {
+			p.task()
+		}
+	})
+}
@@ -0,0 +1,112 @@
+package kinesiswriter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrPoolClosed is returned by Pool.Get after the pool is closed.
+var ErrPoolClosed = errors.New("pool is closed")
+
+// Pool lazily creates and caches Writers per stream ARN.
+// All writers of a pool share one Kinesis client and the same options.
+type Pool struct {
+	factory *Factory
+
+	mu      sync.Mutex
+	writers map[string]*Writer
+	// creating holds the writers being created, so that concurrent Gets wait for one creation.
+	creating map[string]*poolCreation
+	closed   bool
+}
+
+// poolCreation is the creation of a writer of a pool, done once done is closed.
+type poolCreation struct {
+	done chan struct{}
+	w    *Writer
+	err  error
+}
+
+// NewPool creates a new Pool. opts are applied to every writer of the pool.
+// Unless WithKinesisClient is given, a client is created once from the default AWS config.
+func NewPool(ctx context.Context, opts ...WriterConfigOption) (*Pool, error) {
+	factory, err := NewFactory(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return NewPoolFromFactory(factory), nil
+}
+
+// NewPoolFromFactory creates a new Pool creating its writers with factory.
+func NewPoolFromFactory(factory *Factory) *Pool {
+	return &Pool{
+		factory:  factory,
+		writers:  make(map[string]*Writer),
+		creating: make(map[string]*poolCreation),
+	}
+}
+
+// Get returns the Writer for streamARN, creating it on first use.
+// Concurrent Gets of a stream being created wait for the same writer,
+// while the writers of other streams are returned without waiting.
+func (p *Pool) Get(ctx context.Context, streamARN string) (*Writer, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, ErrPoolClosed
+	}
+	if w, ok := p.writers[streamARN]; ok {
+		p.mu.Unlock()
+		return w, nil
+	}
+	if c, ok := p.creating[streamARN]; ok {
+		p.mu.Unlock()
+		select {
+		case <-c.done:
+			return c.w, c.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	c := &poolCreation{done: make(chan struct{})}
+	p.creating[streamARN] = c
+	p.mu.Unlock()
+
+	// New may call AWS and warm up, so it runs without holding the lock.
+	w, err := p.factory.New(ctx, streamARN)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	defer close(c.done)
+	delete(p.creating, streamARN)
+	switch {
+	case err != nil:
+		c.err = fmt.Errorf("failed to create writer for %s: %w", streamARN, err)
+	case p.closed:
+		_ = w.Close()
+		c.err = ErrPoolClosed
+	default:
+		p.writers[streamARN] = w
+		c.w = w
+	}
+	return c.w, c.err
+}
+
+// Close closes all writers of the pool.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return ErrPoolClosed
+	}
+	p.closed = true
+	var errs []error
+	for streamARN, w := range p.writers {
+		if err := w.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close writer for %s: %w", streamARN, err))
+		}
+	}
+	return errors.Join(errs...)
+}
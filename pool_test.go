@@ -0,0 +1,109 @@
+package kinesiswriter_test
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis/types"
+	kinesiswriter "github.com/mackee/go-kinesis-writer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	stream1ARN = "arn:aws:kinesis:ap-northeast-1:123456789012:stream/stream-1"
+	stream2ARN = "arn:aws:kinesis:ap-northeast-1:123456789012:stream/stream-2"
+)
+
+func TestPool(t *testing.T) {
+	ctx := context.Background()
+	client := &successKinesisClient{}
+	pool, err := kinesiswriter.NewPool(ctx,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithImmediateFlush(),
+	)
+	require.NoError(t, err)
+
+	w1, err := pool.Get(ctx, stream1ARN)
+	require.NoError(t, err)
+	w2, err := pool.Get(ctx, stream2ARN)
+	require.NoError(t, err)
+	again, err := pool.Get(ctx, stream1ARN)
+	require.NoError(t, err)
+	assert.Same(t, w1, again)
+	assert.NotSame(t, w1, w2)
+
+	_, err = w1.Write([]byte("record1"))
+	require.NoError(t, err)
+	_, err = w2.Write([]byte("record2"))
+	require.NoError(t, err)
+	require.Len(t, client.Inputs(), 2)
+	assert.Equal(t, stream1ARN, aws.ToString(client.Inputs()[0].StreamARN))
+	assert.Equal(t, stream2ARN, aws.ToString(client.Inputs()[1].StreamARN))
+
+	require.NoError(t, pool.Close())
+	_, err = pool.Get(ctx, stream1ARN)
+	assert.ErrorIs(t, err, kinesiswriter.ErrPoolClosed)
+}
+
+type slowWarmupKinesisClient struct {
+	successKinesisClient
+	// release holds the warm up of stream1ARN until it is closed.
+	release chan struct{}
+
+	mu        sync.Mutex
+	described []string
+}
+
+func (c *slowWarmupKinesisClient) DescribeStreamSummary(ctx context.Context, params *kinesis.DescribeStreamSummaryInput, optFns ...func(*kinesis.Options)) (*kinesis.DescribeStreamSummaryOutput, error) {
+	c.mu.Lock()
+	c.described = append(c.described, aws.ToString(params.StreamARN))
+	c.mu.Unlock()
+	if aws.ToString(params.StreamARN) == stream1ARN {
+		<-c.release
+	}
+	return &kinesis.DescribeStreamSummaryOutput{
+		StreamDescriptionSummary: &types.StreamDescriptionSummary{StreamARN: params.StreamARN, StreamStatus: types.StreamStatusActive},
+	}, nil
+}
+
+func (c *slowWarmupKinesisClient) Described() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Clone(c.described)
+}
+
+func TestPoolSlowWriter(t *testing.T) {
+	ctx := context.Background()
+	client := &slowWarmupKinesisClient{release: make(chan struct{})}
+	pool, err := kinesiswriter.NewPool(ctx,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithWarmup(),
+	)
+	require.NoError(t, err)
+
+	writers := make(chan *kinesiswriter.Writer, 2)
+	for range 2 {
+		go func() {
+			w, err := pool.Get(ctx, stream1ARN)
+			assert.NoError(t, err)
+			writers <- w
+		}()
+	}
+	assert.Eventually(t, func() bool { return len(client.Described()) == 1 }, time.Second, 10*time.Millisecond)
+
+	// a slow stream does not block the others.
+	_, err = pool.Get(ctx, stream2ARN)
+	require.NoError(t, err)
+	assert.Empty(t, writers)
+
+	close(client.release)
+	assert.Same(t, <-writers, <-writers)
+	assert.Equal(t, []string{stream1ARN, stream2ARN}, client.Described(), "concurrent Gets create one writer")
+	require.NoError(t, pool.Close())
+}
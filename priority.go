@@ -0,0 +1,30 @@
+package kinesiswriter
+
+import "bytes"
+
+// Priority is the priority lane of a record.
+type Priority int
+
+const (
+	// PriorityNormal is the lane of records written with Write and ReadFrom,
+	// unless WithPriorityFunc chooses another one.
+	PriorityNormal Priority = iota
+	// PriorityHigh is the lane of records such as errors and audit events. High priority records
+	// have their own buffer, so they do not wait behind normal ones, and they are flushed
+	// first by Sync and Close.
+	PriorityHigh
+)
+
+// WriteRecordWithPriority writes a single record to the lane of priority.
+// Unlike Write, record is not split.
+func (w *Writer) WriteRecordWithPriority(record []byte, priority Priority) error {
+	return w.writeRecords([][]byte{bytes.Clone(record)}, func([]byte) Priority { return priority })
+}
+
+func normalPriority([]byte) Priority {
+	return PriorityNormal
+}
+
+func highPriority([]byte) Priority {
+	return PriorityHigh
+}
@@ -0,0 +1,82 @@
+package kinesiswriter_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	kinesiswriter "github.com/mackee/go-kinesis-writer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterWriteRecordWithPriority(t *testing.T) {
+	client := &successKinesisClient{}
+	writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+		kinesiswriter.WithKinesisClient(client),
+	)
+	require.NoError(t, err)
+
+	_, err = writer.Write([]byte("normal1\nnormal2\n"))
+	require.NoError(t, err)
+	require.NoError(t, writer.WriteRecordWithPriority([]byte("error1\nwith newline"), kinesiswriter.PriorityHigh))
+	require.NoError(t, writer.WriteRecordWithPriority([]byte("normal3"), kinesiswriter.PriorityNormal))
+	require.NoError(t, writer.Close())
+
+	var records []string
+	for _, input := range client.Inputs() {
+		for _, entry := range input.Records {
+			records = append(records, string(entry.Data))
+		}
+	}
+	assert.Equal(t, []string{"error1\nwith newline", "normal1", "normal2", "normal3"}, records)
+}
+
+func TestWriterPriorityFunc(t *testing.T) {
+	client := &successKinesisClient{}
+	writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithPriorityFunc(func(record []byte) kinesiswriter.Priority {
+			if bytes.Contains(record, []byte(`"level":"error"`)) {
+				return kinesiswriter.PriorityHigh
+			}
+			return kinesiswriter.PriorityNormal
+		}),
+	)
+	require.NoError(t, err)
+
+	_, err = writer.Write([]byte(`{"level":"info"}` + "\n" + `{"level":"error"}` + "\n"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	var records []string
+	for _, input := range client.Inputs() {
+		for _, entry := range input.Records {
+			records = append(records, string(entry.Data))
+		}
+	}
+	assert.Equal(t, []string{`{"level":"error"}`, `{"level":"info"}`}, records)
+}
+
+func TestWriterMemoryLimit(t *testing.T) {
+	client := &successKinesisClient{}
+	var shed []string
+	writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithMemoryLimit(1<<30, func() uint64 { return 2 << 30 }),
+		kinesiswriter.WithRejectHandler(func(err error, record []byte) {
+			assert.ErrorIs(t, err, kinesiswriter.ErrMemoryPressure)
+			shed = append(shed, string(record))
+		}),
+	)
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("normal1\n"))
+	require.NoError(t, err)
+	require.NoError(t, writer.WriteRecordWithPriority([]byte("error1"), kinesiswriter.PriorityHigh))
+	require.NoError(t, writer.Close())
+
+	assert.Equal(t, []string{"normal1"}, shed)
+	require.Len(t, client.Inputs(), 1)
+	require.Len(t, client.Inputs()[0].Records, 1)
+	assert.Equal(t, "error1", string(client.Inputs()[0].Records[0].Data))
+}
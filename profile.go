@@ -0,0 +1,46 @@
+package kinesiswriter
+
+import (
+	"fmt"
+	"time"
+)
+
+// Profile is a curated bundle of buffer settings.
+type Profile int
+
+const (
+	// ProfileLowLatency sends small batches often, keeping records in the buffer for at most 100ms.
+	ProfileLowLatency Profile = iota
+	// ProfileThroughput fills PutRecords calls up to the 500 record limit,
+	// flushing every second.
+	ProfileThroughput
+	// ProfileLogging suits piping log output into the writer: medium batches flushed every few
+	// seconds, lines split across writes held until complete, and writes that give up quickly
+	// instead of stalling the application when the buffer is full.
+	ProfileLogging
+)
+
+// WithProfile applies the settings of profile. Options after it override single settings.
+// New fails if profile is unknown.
+func WithProfile(profile Profile) WriterConfigOption {
+	return func(c *writerConfig) {
+		switch profile {
+		case ProfileLowLatency:
+			c.bufferConfig.recordWindow = 50
+			c.bufferConfig.flushInterval = 100 * time.Millisecond
+			c.bufferConfig.flushTimeout = 5 * time.Second
+		case ProfileThroughput:
+			c.bufferConfig.recordWindow = maxRequestRecords
+			c.bufferConfig.flushInterval = time.Second
+			c.bufferConfig.flushTimeout = defaultBufferFlushTimeout
+		case ProfileLogging:
+			c.bufferConfig.recordWindow = 100
+			c.bufferConfig.flushInterval = 5 * time.Second
+			c.bufferConfig.writeTimeout = time.Second
+			c.bufferConfig.flushTimeout = defaultBufferFlushTimeout
+			c.holdPartialLine = true
+		default:
+			c.profileErr = fmt.Errorf("unknown profile %d", profile)
+		}
+	}
+}
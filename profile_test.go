@@ -0,0 +1,95 @@
+package kinesiswriter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/kinesis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type noopKinesisClient struct{}
+
+func (noopKinesisClient) PutRecords(context.Context, *kinesis.PutRecordsInput, ...func(*kinesis.Options)) (*kinesis.PutRecordsOutput, error) {
+	return &kinesis.PutRecordsOutput{}, nil
+}
+
+func TestWithProfile(t *testing.T) {
+	type settings struct {
+		recordWindow    uint32
+		flushInterval   time.Duration
+		flushTimeout    time.Duration
+		writeTimeout    time.Duration
+		holdPartialLine bool
+	}
+	tests := []struct {
+		name    string
+		profile Profile
+		want    settings
+	}{
+		{
+			name:    "low latency",
+			profile: ProfileLowLatency,
+			want: settings{
+				recordWindow:  50,
+				flushInterval: 100 * time.Millisecond,
+				flushTimeout:  5 * time.Second,
+				writeTimeout:  defaultBufferWriteTimeout,
+			},
+		},
+		{
+			name:    "throughput",
+			profile: ProfileThroughput,
+			want: settings{
+				recordWindow:  500,
+				flushInterval: time.Second,
+				flushTimeout:  defaultBufferFlushTimeout,
+				writeTimeout:  defaultBufferWriteTimeout,
+			},
+		},
+		{
+			name:    "logging",
+			profile: ProfileLogging,
+			want: settings{
+				recordWindow:    100,
+				flushInterval:   5 * time.Second,
+				flushTimeout:    defaultBufferFlushTimeout,
+				writeTimeout:    time.Second,
+				holdPartialLine: true,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf, err := newWriterConfig(context.Background(), []WriterConfigOption{
+				WithKinesisClient(noopKinesisClient{}),
+				WithProfile(tt.profile),
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, settings{
+				recordWindow:    conf.bufferConfig.recordWindow,
+				flushInterval:   conf.bufferConfig.flushInterval,
+				flushTimeout:    conf.bufferConfig.flushTimeout,
+				writeTimeout:    conf.bufferConfig.writeTimeout,
+				holdPartialLine: conf.holdPartialLine,
+			})
+		})
+	}
+
+	conf, err := newWriterConfig(context.Background(), []WriterConfigOption{
+		WithKinesisClient(noopKinesisClient{}),
+		WithProfile(ProfileThroughput),
+		WithBufferRecordWindow(10),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, uint32(10), conf.bufferConfig.recordWindow, "options after the profile override it")
+	assert.Equal(t, time.Second, conf.bufferConfig.flushInterval)
+
+	_, err = newWriterConfig(context.Background(), []WriterConfigOption{
+		WithKinesisClient(noopKinesisClient{}),
+		WithProfile(Profile(42)),
+	})
+	assert.ErrorContains(t, err, "unknown profile 42")
+}
@@ -0,0 +1,112 @@
+package kinesiswriter
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis/types"
+)
+
+// maxRecordedLineSize fits a base64 encoded PutRecords request of the maximum size.
+const maxRecordedLineSize = 16 << 20
+
+// RecordingClient is a KinesisClient that writes every PutRecords request as a line of JSON
+// before passing it to the wrapped client. Together with LoadRecordedInputs it allows
+// snapshot tests of exactly what would be sent to Kinesis.
+type RecordingClient struct {
+	client KinesisClient
+
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+type recordedInput struct {
+	StreamARN  string          `json:"streamARN,omitempty"`
+	StreamName string          `json:"streamName,omitempty"`
+	Records    []recordedEntry `json:"records"`
+}
+
+type recordedEntry struct {
+	Data            []byte  `json:"data"`
+	PartitionKey    string  `json:"partitionKey"`
+	ExplicitHashKey *string `json:"explicitHashKey,omitempty"`
+}
+
+// NewRecordingClient creates a RecordingClient writing requests to w.
+// If client is nil, requests are not sent anywhere and every record is reported as accepted.
+func NewRecordingClient(client KinesisClient, w io.Writer) *RecordingClient {
+	return &RecordingClient{
+		client: client,
+		enc:    json.NewEncoder(w),
+	}
+}
+
+// PutRecords records params and calls PutRecords of the wrapped client.
+func (c *RecordingClient) PutRecords(ctx context.Context, params *kinesis.PutRecordsInput, optFns ...func(*kinesis.Options)) (*kinesis.PutRecordsOutput, error) {
+	ri := recordedInput{
+		StreamARN:  aws.ToString(params.StreamARN),
+		StreamName: aws.ToString(params.StreamName),
+		Records:    make([]recordedEntry, len(params.Records)),
+	}
+	for i, e := range params.Records {
+		ri.Records[i] = recordedEntry{
+			Data:            e.Data,
+			PartitionKey:    aws.ToString(e.PartitionKey),
+			ExplicitHashKey: e.ExplicitHashKey,
+		}
+	}
+	c.mu.Lock()
+	err := c.enc.Encode(ri)
+	c.mu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to record put records input: %w", err)
+	}
+
+	if c.client == nil {
+		return &kinesis.PutRecordsOutput{
+			Records:           make([]types.PutRecordsResultEntry, len(params.Records)),
+			FailedRecordCount: aws.Int32(0),
+		}, nil
+	}
+	return c.client.PutRecords(ctx, params, optFns...)
+}
+
+// LoadRecordedInputs reads PutRecords requests written by a RecordingClient.
+func LoadRecordedInputs(r io.Reader) ([]*kinesis.PutRecordsInput, error) {
+	var inputs []*kinesis.PutRecordsInput
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxRecordedLineSize)
+	for scanner.Scan() {
+		var ri recordedInput
+		if err := json.Unmarshal(scanner.Bytes(), &ri); err != nil {
+			return nil, fmt.Errorf("failed to decode recorded input at line %d: %w", len(inputs)+1, err)
+		}
+		input := &kinesis.PutRecordsInput{
+			Records: make([]types.PutRecordsRequestEntry, len(ri.Records)),
+		}
+		if ri.StreamARN != "" {
+			input.StreamARN = aws.String(ri.StreamARN)
+		}
+		if ri.StreamName != "" {
+			input.StreamName = aws.String(ri.StreamName)
+		}
+		for i, e := range ri.Records {
+			input.Records[i] = types.PutRecordsRequestEntry{
+				Data:            e.Data,
+				PartitionKey:    aws.String(e.PartitionKey),
+				ExplicitHashKey: e.ExplicitHashKey,
+			}
+		}
+		inputs = append(inputs, input)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read recorded inputs: %w", err)
+	}
+	return inputs, nil
+}
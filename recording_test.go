@@ -0,0 +1,54 @@
+package kinesiswriter_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis/types"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	kinesiswriter "github.com/mackee/go-kinesis-writer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordingClient(t *testing.T) {
+	ctx := context.Background()
+	var golden bytes.Buffer
+	client := kinesiswriter.NewRecordingClient(nil, &golden)
+	writer, err := kinesiswriter.New(ctx, testStreamARN,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithImmediateFlush(),
+		kinesiswriter.WithSequentialPartitionKeys(),
+	)
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("record1\nrecord2"))
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("record3"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	inputs, err := kinesiswriter.LoadRecordedInputs(&golden)
+	require.NoError(t, err)
+	expect := []*kinesis.PutRecordsInput{
+		{
+			Records: []types.PutRecordsRequestEntry{
+				{Data: []byte("record1"), PartitionKey: aws.String("0")},
+				{Data: []byte("record2"), PartitionKey: aws.String("1")},
+			},
+			StreamARN: aws.String(testStreamARN),
+		},
+		{
+			Records: []types.PutRecordsRequestEntry{
+				{Data: []byte("record3"), PartitionKey: aws.String("2")},
+			},
+			StreamARN: aws.String(testStreamARN),
+		},
+	}
+	opts := cmpopts.IgnoreUnexported(kinesis.PutRecordsInput{}, types.PutRecordsRequestEntry{})
+	if diff := cmp.Diff(expect, inputs, opts); diff != "" {
+		t.Errorf("unexpected recorded inputs (-want, +got):\n%s", diff)
+	}
+}
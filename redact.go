@@ -0,0 +1,31 @@
+package kinesiswriter
+
+import "fmt"
+
+// Redactor renders a record in the messages of the default handlers.
+type Redactor func(record []byte) string
+
+func redactNone(record []byte) string {
+	return string(record)
+}
+
+// RedactOmit is a Redactor rendering only the size of records.
+func RedactOmit(record []byte) string {
+	return fmt.Sprintf("<%d bytes>", len(record))
+}
+
+// RedactHash is a Redactor rendering records as their SHA-256 hash,
+// as in DeliveryReceipt.RecordHash.
+func RedactHash(record []byte) string {
+	return "sha256:" + recordHash(record)
+}
+
+// RedactTruncate returns a Redactor rendering the first n bytes of records.
+func RedactTruncate(n int) Redactor {
+	return func(record []byte) string {
+		if len(record) <= n {
+			return string(record)
+		}
+		return fmt.Sprintf("%s...<%d bytes>", record[:n], len(record))
+	}
+}
@@ -0,0 +1,16 @@
+package kinesiswriter_test
+
+import (
+	"testing"
+
+	kinesiswriter "github.com/mackee/go-kinesis-writer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactors(t *testing.T) {
+	record := []byte(`{"email":"user@example.com"}`)
+	assert.Equal(t, "<28 bytes>", kinesiswriter.RedactOmit(record))
+	assert.Equal(t, `{"email...<28 bytes>`, kinesiswriter.RedactTruncate(7)(record))
+	assert.Equal(t, string(record), kinesiswriter.RedactTruncate(100)(record))
+	assert.Regexp(t, `^sha256:[0-9a-f]{64}$`, kinesiswriter.RedactHash(record))
+}
@@ -0,0 +1,53 @@
+package kinesiswriter
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis"
+)
+
+// requestLogMiddleware logs every PutRecords call at debug level with its AWS request ID,
+// batch size, duration and failed record count.
+func requestLogMiddleware(logger *slog.Logger) PutRecordsMiddleware {
+	return func(next PutRecordsFunc) PutRecordsFunc {
+		return func(ctx context.Context, params *kinesis.PutRecordsInput, optFns ...func(*kinesis.Options)) (*kinesis.PutRecordsOutput, error) {
+			start := time.Now()
+			out, err := next(ctx, params, optFns...)
+			attrs := []slog.Attr{
+				slog.Int("records", len(params.Records)),
+				slog.Duration("duration", time.Since(start)),
+			}
+			if err != nil {
+				if requestID := errorRequestID(err); requestID != "" {
+					attrs = append(attrs, slog.String("request_id", requestID))
+				}
+				attrs = append(attrs, slog.String("error", err.Error()))
+				logger.LogAttrs(ctx, slog.LevelDebug, "put records failed", attrs...)
+				return out, err
+			}
+			if requestID, ok := awsmiddleware.GetRequestIDMetadata(out.ResultMetadata); ok {
+				attrs = append(attrs, slog.String("request_id", requestID))
+			}
+			var failed int32
+			if out.FailedRecordCount != nil {
+				failed = *out.FailedRecordCount
+			}
+			attrs = append(attrs, slog.Int("failed_records", int(failed)))
+			logger.LogAttrs(ctx, slog.LevelDebug, "put records", attrs...)
+			return out, err
+		}
+	}
+}
+
+// errorRequestID returns the AWS request ID carried by err, if any.
+func errorRequestID(err error) string {
+	var re interface{ ServiceRequestID() string }
+	if errors.As(err, &re) {
+		return re.ServiceRequestID()
+	}
+	return ""
+}
@@ -0,0 +1,72 @@
+package kinesiswriter
+
+import (
+	"time"
+)
+
+const retryMaxCount = 3
+
+var defaultRetryBackoff = RetryBackoff{
+	MinDelay: 5 * time.Second,
+	MaxDelay: 30 * time.Second,
+}
+
+// Retrier decides whether and when records failed by a PutRecords attempt are retried.
+// Records failed with a permanent error code are never retried.
+type Retrier interface {
+	// NextDelay returns the delay before retrying after the attempt-th attempt of a flush,
+	// counting from 1, and false to give up. errorCodes are the error codes of the records
+	// to retry.
+	NextDelay(attempt int, errorCodes []string) (time.Duration, bool)
+}
+
+// RetryBackoff is an exponential backoff curve for retrying failed records.
+// The delay starts at MinDelay and doubles on every retry up to MaxDelay.
+type RetryBackoff struct {
+	MinDelay time.Duration
+	MaxDelay time.Duration
+}
+
+func (b RetryBackoff) delay(n int) time.Duration {
+	d := b.MinDelay
+	for i := 1; i < n && d < b.MaxDelay; i++ {
+		d *= 2
+	}
+	return min(d, max(b.MaxDelay, b.MinDelay))
+}
+
+// backoffRetrier is the default Retrier. The first retry is immediate, following ones
+// back off with the longest delay among the backoffs of the error codes.
+type backoffRetrier struct {
+	maxRetries int
+	backoffs   map[string]RetryBackoff
+}
+
+func (r *backoffRetrier) NextDelay(attempt int, errorCodes []string) (time.Duration, bool) {
+	if attempt > r.maxRetries {
+		return 0, false
+	}
+	if attempt == 1 {
+		return 0, true
+	}
+	n := attempt - 1
+	if len(errorCodes) == 0 {
+		return defaultRetryBackoff.delay(n), true
+	}
+	var delay time.Duration
+	for _, code := range errorCodes {
+		backoff, ok := r.backoffs[code]
+		if !ok {
+			backoff = defaultRetryBackoff
+		}
+		delay = max(delay, backoff.delay(n))
+	}
+	return delay, true
+}
+
+// noRetrier never retries.
+type noRetrier struct{}
+
+func (noRetrier) NextDelay(int, []string) (time.Duration, bool) {
+	return 0, false
+}
@@ -0,0 +1,91 @@
+package kinesiswriter
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// route delivers the records whose stream key maps to another stream.
+type route struct {
+	flusher *flusher
+	buffer  Buffer
+}
+
+type streamRoutes struct {
+	key    func(record []byte) string
+	routes map[string]*route
+	// ordered holds the routes in the order of their keys.
+	ordered []*route
+}
+
+// newStreamRoutes creates a flusher and a buffer per stream of the stream map.
+// The route flushers share the stats and health of fl, so the writer reports on all streams.
+// Buffers are created once every flusher is, so that none is left running on error.
+func newStreamRoutes(conf *writerConfig, fl *flusher, flushTimeout time.Duration) (*streamRoutes, error) {
+	rs := &streamRoutes{key: conf.streamKey, routes: make(map[string]*route, len(conf.streams))}
+	keys := make([]string, 0, len(conf.streams))
+	for key := range conf.streams {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		streamARN := conf.streams[key]
+		if _, err := ParseStreamARN(streamARN); err != nil {
+			return nil, fmt.Errorf("invalid stream for key %q: %w", key, err)
+		}
+		rf, err := newFlusher(conf, streamARN)
+		if err != nil {
+			return nil, err
+		}
+		rf.stats, rf.health = fl.stats, fl.health
+		r := &route{flusher: rf}
+		rs.routes[key] = r
+		rs.ordered = append(rs.ordered, r)
+	}
+	for _, r := range rs.ordered {
+		r.buffer = newBuffer(r.flusher, conf, flushTimeout)
+	}
+	return rs, nil
+}
+
+// lookup returns the route of record, or nil if it goes to the default stream.
+func (rs *streamRoutes) lookup(record []byte) *route {
+	if rs == nil {
+		return nil
+	}
+	return rs.routes[rs.key(record)]
+}
+
+// sorted returns the routes in the order of their keys.
+func (rs *streamRoutes) sorted() []*route {
+	if rs == nil {
+		return nil
+	}
+	return rs.ordered
+}
+
+// group splits records by their flusher, starting with fl for the default stream.
+func (rs *streamRoutes) group(records []Record, fl *flusher) ([]*flusher, [][]Record) {
+	if rs == nil {
+		return []*flusher{fl}, [][]Record{records}
+	}
+	flushers := []*flusher{fl}
+	batches := [][]Record{nil}
+	index := map[*flusher]int{fl: 0}
+	for _, record := range records {
+		target := fl
+		if r := rs.lookup(record.Data); r != nil {
+			target = r.flusher
+		}
+		i, ok := index[target]
+		if !ok {
+			i = len(flushers)
+			index[target] = i
+			flushers = append(flushers, target)
+			batches = append(batches, nil)
+		}
+		batches[i] = append(batches[i], record)
+	}
+	return flushers, batches
+}
@@ -0,0 +1,152 @@
+package kinesiswriter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis/types"
+)
+
+// KinesisShardUpdater is implemented by Kinesis clients that can reshard a stream.
+// *kinesis.Client implements it.
+type KinesisShardUpdater interface {
+	UpdateShardCount(ctx context.Context, params *kinesis.UpdateShardCountInput, optFns ...func(*kinesis.Options)) (*kinesis.UpdateShardCountOutput, error)
+}
+
+// ScaleSignal reports throttling sustained over a window, a sign that the stream needs more shards.
+type ScaleSignal struct {
+	// Records is the number of record deliveries attempted in the window.
+	Records int
+	// Throttled is the number of those deliveries rejected by throttling.
+	Throttled int
+	// Window is the time the counts were collected over.
+	Window time.Duration
+	// CurrentShards is the number of open shards, or 0 if unknown.
+	CurrentShards int
+	// TargetShards is the shard count requested with UpdateShardCount, or 0 if none was requested.
+	TargetShards int
+	// Err is the error of listing or updating the shards, if any.
+	Err error
+}
+
+type scaleSignalConfig struct {
+	threshold float64
+	window    time.Duration
+	callback  func(ScaleSignal)
+}
+
+// scaleOutDetector counts throttled record deliveries and signals windows in which
+// their share reaches the threshold, optionally scaling the stream out.
+type scaleOutDetector struct {
+	scaleSignalConfig
+	streamARN string
+	lister    KinesisShardLister
+	updater   KinesisShardUpdater
+	maxShards int
+
+	mu        sync.Mutex
+	start     time.Time
+	records   int
+	throttled int
+	// scaling is set while shards are listed or updated in the background.
+	scaling atomic.Bool
+}
+
+const scaleOutTimeout = 10 * time.Second
+
+func (d *scaleOutDetector) observe(records, throttled int) {
+	now := time.Now()
+	d.mu.Lock()
+	if d.start.IsZero() {
+		d.start = now
+	}
+	d.records += records
+	d.throttled += throttled
+	elapsed := now.Sub(d.start)
+	if elapsed < d.window {
+		d.mu.Unlock()
+		return
+	}
+	signal := ScaleSignal{Records: d.records, Throttled: d.throttled, Window: elapsed}
+	d.start, d.records, d.throttled = now, 0, 0
+	d.mu.Unlock()
+
+	if signal.Throttled == 0 || float64(signal.Throttled)/float64(signal.Records) < d.threshold {
+		return
+	}
+	// without a lister, or while a previous scale out is running, report the signal as it is.
+	if d.lister == nil || !d.scaling.CompareAndSwap(false, true) {
+		d.callback(signal)
+		return
+	}
+	// control plane calls are slow and throttled, so keep them off the flush path.
+	go func() {
+		defer d.scaling.Store(false)
+		d.scaleOut(&signal)
+		d.callback(signal)
+	}()
+}
+
+// scaleOut doubles the open shards up to maxShards, the most a single UpdateShardCount allows.
+func (d *scaleOutDetector) scaleOut(signal *ScaleSignal) {
+	ctx, cancel := context.WithTimeout(context.Background(), scaleOutTimeout)
+	defer cancel()
+	shards, err := listOpenShards(ctx, d.lister, d.streamARN)
+	if err != nil {
+		signal.Err = fmt.Errorf("failed to list shards: %w", err)
+		return
+	}
+	signal.CurrentShards = len(shards)
+	if d.updater == nil || signal.CurrentShards >= d.maxShards {
+		return
+	}
+	target := min(signal.CurrentShards*2, d.maxShards)
+	_, err = d.updater.UpdateShardCount(ctx, &kinesis.UpdateShardCountInput{
+		StreamARN:        aws.String(d.streamARN),
+		TargetShardCount: aws.Int32(int32(target)),
+		ScalingType:      types.ScalingTypeUniformScaling,
+	})
+	if err != nil {
+		signal.Err = fmt.Errorf("failed to update shard count: %w", err)
+		return
+	}
+	signal.TargetShards = target
+}
+
+// newScaleOutDetector creates the detector configured by conf, or nil if no scale signal is set.
+func newScaleOutDetector(conf *writerConfig, streamARN string) (*scaleOutDetector, error) {
+	if conf.scaleSignal == nil {
+		if conf.autoScaleMaxShards > 0 {
+			return nil, errors.New("auto scale out requires WithScaleOutSignal")
+		}
+		return nil, nil
+	}
+	d := &scaleOutDetector{scaleSignalConfig: *conf.scaleSignal, streamARN: streamARN}
+	d.lister, _ = conf.client.(KinesisShardLister)
+	if conf.autoScaleMaxShards > 0 {
+		updater, ok := conf.client.(KinesisShardUpdater)
+		if !ok || d.lister == nil {
+			return nil, errors.New("auto scale out requires a client implementing KinesisShardLister and KinesisShardUpdater")
+		}
+		d.updater = updater
+		d.maxShards = conf.autoScaleMaxShards
+	}
+	return d, nil
+}
+
+// throttled counts the throttled entries of a PutRecords result.
+func throttled(entries []types.PutRecordsResultEntry) int {
+	var n int
+	for _, e := range entries {
+		if e.ErrorCode != nil && isThrottling(*e.ErrorCode) {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,101 @@
+package kinesiswriter_test
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis/types"
+	"github.com/aws/smithy-go"
+	kinesiswriter "github.com/mackee/go-kinesis-writer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type scalingKinesisClient struct {
+	errorKinesisClient
+	shards []types.Shard
+	// release holds ListShards until it is closed, if set.
+	release chan struct{}
+
+	mu      sync.Mutex
+	updates []*kinesis.UpdateShardCountInput
+}
+
+func (c *scalingKinesisClient) ListShards(ctx context.Context, params *kinesis.ListShardsInput, optFns ...func(*kinesis.Options)) (*kinesis.ListShardsOutput, error) {
+	if c.release != nil {
+		<-c.release
+	}
+	return &kinesis.ListShardsOutput{Shards: c.shards}, nil
+}
+
+func (c *scalingKinesisClient) UpdateShardCount(ctx context.Context, params *kinesis.UpdateShardCountInput, optFns ...func(*kinesis.Options)) (*kinesis.UpdateShardCountOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.updates = append(c.updates, params)
+	return &kinesis.UpdateShardCountOutput{}, nil
+}
+
+func (c *scalingKinesisClient) Updates() []*kinesis.UpdateShardCountInput {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Clone(c.updates)
+}
+
+func TestWriterScaleOutSignal(t *testing.T) {
+	ctx := context.Background()
+	client := &scalingKinesisClient{
+		errorKinesisClient: errorKinesisClient{err: &smithy.GenericAPIError{Code: "ProvisionedThroughputExceededException"}},
+		shards:             []types.Shard{{ShardId: aws.String("shardId-0")}, {ShardId: aws.String("shardId-1")}},
+		release:            make(chan struct{}),
+	}
+	signals := make(chan kinesiswriter.ScaleSignal, 10)
+	writer, err := kinesiswriter.New(ctx, testStreamARN,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithImmediateFlush(),
+		kinesiswriter.WithNoRetry(),
+		kinesiswriter.WithScaleOutSignal(0.5, 50*time.Millisecond, func(s kinesiswriter.ScaleSignal) {
+			signals <- s
+		}),
+		kinesiswriter.WithAutoScaleOut(3),
+	)
+	require.NoError(t, err)
+	_, _ = writer.Write([]byte("record1\nrecord2\n"))
+	assert.Empty(t, signals)
+	time.Sleep(60 * time.Millisecond)
+	// the flush does not wait for the shards to be listed.
+	_, _ = writer.Write([]byte("record3\n"))
+	assert.Empty(t, signals)
+
+	// signals fired while scaling out are reported as they are.
+	time.Sleep(60 * time.Millisecond)
+	_, _ = writer.Write([]byte("record4\n"))
+	require.Len(t, signals, 1)
+	signal := <-signals
+	assert.Equal(t, 1, signal.Records)
+	assert.Equal(t, 1, signal.Throttled)
+	assert.Zero(t, signal.CurrentShards)
+	assert.Zero(t, signal.TargetShards)
+
+	close(client.release)
+	signal = <-signals
+	assert.Equal(t, 3, signal.Records)
+	assert.Equal(t, 3, signal.Throttled)
+	assert.Equal(t, 2, signal.CurrentShards)
+	assert.Equal(t, 3, signal.TargetShards)
+	require.NoError(t, signal.Err)
+	require.Len(t, client.Updates(), 1)
+	assert.Equal(t, int32(3), aws.ToInt32(client.Updates()[0].TargetShardCount))
+	_ = writer.Close()
+
+	_, err = kinesiswriter.New(ctx, testStreamARN,
+		kinesiswriter.WithKinesisClient(&successKinesisClient{}),
+		kinesiswriter.WithScaleOutSignal(0.5, time.Minute, func(kinesiswriter.ScaleSignal) {}),
+		kinesiswriter.WithAutoScaleOut(3),
+	)
+	assert.Error(t, err)
+}
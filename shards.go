@@ -0,0 +1,110 @@
+package kinesiswriter
+
+import (
+	"context"
+	"crypto/md5"
+	"fmt"
+	"math/big"
+	"slices"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis/types"
+)
+
+// KinesisShardLister is implemented by Kinesis clients that can list the shards of a stream.
+// *kinesis.Client implements it.
+type KinesisShardLister interface {
+	ListShards(ctx context.Context, params *kinesis.ListShardsInput, optFns ...func(*kinesis.Options)) (*kinesis.ListShardsOutput, error)
+}
+
+// ShardHashRange is the hash key range of an open shard. Both ends are inclusive.
+type ShardHashRange struct {
+	ShardID         string
+	StartingHashKey *big.Int
+	EndingHashKey   *big.Int
+}
+
+// Contains reports whether hashKey falls in the range.
+func (r ShardHashRange) Contains(hashKey *big.Int) bool {
+	return r.StartingHashKey.Cmp(hashKey) <= 0 && hashKey.Cmp(r.EndingHashKey) <= 0
+}
+
+// ShardHashRanges are the hash key ranges of the open shards of a stream, sorted by starting hash key.
+type ShardHashRanges []ShardHashRange
+
+// ForPartitionKey returns the range of the shard that Kinesis maps partitionKey to.
+func (rs ShardHashRanges) ForPartitionKey(partitionKey string) (ShardHashRange, bool) {
+	hashKey := PartitionKeyHash(partitionKey)
+	for _, r := range rs {
+		if r.Contains(hashKey) {
+			return r, true
+		}
+	}
+	return ShardHashRange{}, false
+}
+
+// ForIndex returns the i-th range, wrapping around the number of ranges.
+// It panics if rs is empty.
+func (rs ShardHashRanges) ForIndex(i int) ShardHashRange {
+	return rs[i%len(rs)]
+}
+
+// PartitionKeyHash returns the 128-bit hash key Kinesis computes from a partition key,
+// the MD5 digest of the key as an unsigned integer.
+func PartitionKeyHash(partitionKey string) *big.Int {
+	sum := md5.Sum([]byte(partitionKey))
+	return new(big.Int).SetBytes(sum[:])
+}
+
+// ListShardHashRanges lists the open shards of a stream with ListShards
+// and returns their hash key ranges.
+func ListShardHashRanges(ctx context.Context, lister KinesisShardLister, streamARN string) (ShardHashRanges, error) {
+	shards, err := listOpenShards(ctx, lister, streamARN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list shards: %w", err)
+	}
+	ranges := make(ShardHashRanges, 0, len(shards))
+	for _, s := range shards {
+		if s.HashKeyRange == nil {
+			continue
+		}
+		start, ok := new(big.Int).SetString(aws.ToString(s.HashKeyRange.StartingHashKey), 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid starting hash key of %s: %q", aws.ToString(s.ShardId), aws.ToString(s.HashKeyRange.StartingHashKey))
+		}
+		end, ok := new(big.Int).SetString(aws.ToString(s.HashKeyRange.EndingHashKey), 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid ending hash key of %s: %q", aws.ToString(s.ShardId), aws.ToString(s.HashKeyRange.EndingHashKey))
+		}
+		ranges = append(ranges, ShardHashRange{
+			ShardID:         aws.ToString(s.ShardId),
+			StartingHashKey: start,
+			EndingHashKey:   end,
+		})
+	}
+	slices.SortFunc(ranges, func(a, b ShardHashRange) int {
+		return a.StartingHashKey.Cmp(b.StartingHashKey)
+	})
+	return ranges, nil
+}
+
+// listOpenShards returns the open shards of the stream, following pagination.
+func listOpenShards(ctx context.Context, lister KinesisShardLister, streamARN string) ([]types.Shard, error) {
+	input := &kinesis.ListShardsInput{
+		StreamARN:   aws.String(streamARN),
+		ShardFilter: &types.ShardFilter{Type: types.ShardFilterTypeAtLatest},
+	}
+	var shards []types.Shard
+	for {
+		out, err := lister.ListShards(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		shards = append(shards, out.Shards...)
+		if out.NextToken == nil {
+			return shards, nil
+		}
+		input = &kinesis.ListShardsInput{NextToken: out.NextToken}
+	}
+}
@@ -0,0 +1,39 @@
+package kinesiswriter_test
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis/types"
+	kinesiswriter "github.com/mackee/go-kinesis-writer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListShardHashRanges(t *testing.T) {
+	half := new(big.Int).Lsh(big.NewInt(1), 127)
+	top := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 128), big.NewInt(1))
+	client := &shardListerKinesisClient{
+		shards: []types.Shard{
+			{ShardId: aws.String("shardId-1"), HashKeyRange: &types.HashKeyRange{StartingHashKey: aws.String(half.String()), EndingHashKey: aws.String(top.String())}},
+			{ShardId: aws.String("shardId-0"), HashKeyRange: &types.HashKeyRange{StartingHashKey: aws.String("0"), EndingHashKey: aws.String(new(big.Int).Sub(half, big.NewInt(1)).String())}},
+		},
+	}
+	ranges, err := kinesiswriter.ListShardHashRanges(context.Background(), client, testStreamARN)
+	require.NoError(t, err)
+	require.Len(t, ranges, 2)
+	assert.Equal(t, "shardId-0", ranges[0].ShardID)
+	assert.Equal(t, "shardId-1", ranges[1].ShardID)
+	assert.Equal(t, "shardId-0", ranges.ForIndex(2).ShardID)
+
+	// md5("a") = 0cc175b9..., which is in the lower half.
+	r, ok := ranges.ForPartitionKey("a")
+	assert.True(t, ok)
+	assert.Equal(t, "shardId-0", r.ShardID)
+	// md5("b") = 92eb5ffe..., which is in the upper half.
+	r, ok = ranges.ForPartitionKey("b")
+	assert.True(t, ok)
+	assert.Equal(t, "shardId-1", r.ShardID)
+}
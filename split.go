@@ -0,0 +1,139 @@
+package kinesiswriter
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+)
+
+// FramingError reports input that the split function failed to frame into records.
+// Split functions set with WithSplitFunc signal unrecoverable framing errors by returning
+// an error, which Write and ReadFrom return as a *FramingError.
+type FramingError struct {
+	// Offset is the byte offset in the input of the data that could not be framed.
+	// The records before it have been written.
+	Offset int64
+	Err    error
+}
+
+func (e *FramingError) Error() string {
+	return fmt.Sprintf("failed to frame records at byte %d: %s", e.Offset, e.Err)
+}
+
+func (e *FramingError) Unwrap() error {
+	return e.Err
+}
+
+// framingSplit wraps a split function to keep the offset of its error.
+type framingSplit struct {
+	split  bufio.SplitFunc
+	offset int64
+	err    error
+}
+
+func (s *framingSplit) scan(data []byte, atEOF bool) (int, []byte, error) {
+	advance, token, err := s.split(data, atEOF)
+	if err != nil && err != bufio.ErrFinalToken {
+		s.err = err
+		return advance, token, err
+	}
+	s.offset += int64(advance)
+	return advance, token, err
+}
+
+// framingError returns the error of the split function as a *FramingError, or nil.
+func (s *framingSplit) framingError() error {
+	if s.err == nil {
+		return nil
+	}
+	return &FramingError{Offset: s.offset, Err: s.err}
+}
+
+// ScanMultiline returns a bufio.SplitFunc grouping lines into records, where a record
+// starts at a line matching start and continues with the following lines that do not,
+// such as the lines of a stack trace. The newlines between grouped lines are kept.
+// A record is complete only once the next record starts or the input ends.
+func ScanMultiline(start *regexp.Regexp) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		if atEOF && len(data) == 0 {
+			return 0, nil, nil
+		}
+		for i := bytes.IndexByte(data, '\n'); i >= 0; {
+			next := data[i+1:]
+			j := bytes.IndexByte(next, '\n')
+			line := next
+			if j >= 0 {
+				line = next[:j]
+			} else if !atEOF {
+				// the next line is incomplete yet.
+				return 0, nil, nil
+			}
+			if len(line) > 0 && start.Match(dropCR(line)) {
+				return i + 1, dropCR(data[:i]), nil
+			}
+			if j < 0 {
+				break
+			}
+			i += j + 1
+		}
+		if atEOF {
+			return len(data), dropCR(bytes.TrimSuffix(data, []byte("\n"))), nil
+		}
+		return 0, nil, nil
+	}
+}
+
+// SplitByRegex returns a bufio.SplitFunc starting a new record at each match of pattern.
+// Data before the first match is a record of its own, and trailing newlines are
+// trimmed from each record. Use (?m)^ to anchor pattern at the start of a line.
+// A record is complete only once the next match is found or the input ends.
+func SplitByRegex(pattern *regexp.Regexp) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		if atEOF && len(data) == 0 {
+			return 0, nil, nil
+		}
+		// the match at the start of data, if any, begins the current record.
+		for _, loc := range pattern.FindAllIndex(data, 2) {
+			if loc[0] == 0 {
+				continue
+			}
+			if loc[1] == len(data) && !atEOF {
+				// the match may change with more data.
+				return 0, nil, nil
+			}
+			return loc[0], trimNewlines(data[:loc[0]]), nil
+		}
+		if atEOF {
+			return len(data), trimNewlines(data), nil
+		}
+		return 0, nil, nil
+	}
+}
+
+// trimNewlines trims trailing newlines, returning nil for a blank record so that it is skipped.
+func trimNewlines(data []byte) []byte {
+	data = bytes.TrimRight(data, "\r\n")
+	if len(data) == 0 {
+		return nil
+	}
+	return data
+}
+
+// scanRawLines is bufio.ScanLines keeping a trailing \r.
+func scanRawLines(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+func dropCR(data []byte) []byte {
+	return bytes.TrimSuffix(data, []byte("\r"))
+}
@@ -0,0 +1,120 @@
+package kinesiswriter_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	kinesiswriter "github.com/mackee/go-kinesis-writer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func scanAll(t *testing.T, input string, split bufio.SplitFunc) []string {
+	t.Helper()
+	// a small reader exercises records spanning several reads.
+	scanner := bufio.NewScanner(&smallReader{r: strings.NewReader(input), n: 3})
+	scanner.Split(split)
+	var tokens []string
+	for scanner.Scan() {
+		tokens = append(tokens, scanner.Text())
+	}
+	require.NoError(t, scanner.Err())
+	return tokens
+}
+
+type smallReader struct {
+	r io.Reader
+	n int
+}
+
+func (r *smallReader) Read(p []byte) (int, error) {
+	return r.r.Read(p[:min(len(p), r.n)])
+}
+
+func TestScanMultiline(t *testing.T) {
+	start := regexp.MustCompile(`^\d{4}-\d{2}-\d{2} `)
+	input := "2024-01-01 first\n" +
+		"2024-01-01 error\n" +
+		"java.lang.Exception: boom\r\n" +
+		"\tat Main.main(Main.java:1)\n" +
+		"2024-01-02 last\n"
+	got := scanAll(t, input, kinesiswriter.ScanMultiline(start))
+	assert.Equal(t, []string{
+		"2024-01-01 first",
+		"2024-01-01 error\njava.lang.Exception: boom\r\n\tat Main.main(Main.java:1)",
+		"2024-01-02 last",
+	}, got)
+
+	got = scanAll(t, "orphan\n2024-01-01 x", kinesiswriter.ScanMultiline(start))
+	assert.Equal(t, []string{"orphan", "2024-01-01 x"}, got)
+}
+
+func TestSplitByRegex(t *testing.T) {
+	pattern := regexp.MustCompile(`(?m)^<\d+>`)
+	input := "\n<1>first\ncontinued\n<2>second\n\n<3>third"
+	got := scanAll(t, input, kinesiswriter.SplitByRegex(pattern))
+	assert.Equal(t, []string{"<1>first\ncontinued", "<2>second", "<3>third"}, got)
+
+	got = scanAll(t, "header BEGIN a BEGIN b", kinesiswriter.SplitByRegex(regexp.MustCompile(`BEGIN`)))
+	assert.Equal(t, []string{"header ", "BEGIN a ", "BEGIN b"}, got)
+}
+
+func TestWriterFramingError(t *testing.T) {
+	errBadFrame := errors.New("bad frame")
+	// records are framed by "|", and "!" is invalid.
+	split := func(data []byte, atEOF bool) (int, []byte, error) {
+		if atEOF && len(data) == 0 {
+			return 0, nil, nil
+		}
+		for i, b := range data {
+			switch b {
+			case '|':
+				return i + 1, data[:i], nil
+			case '!':
+				return 0, nil, errBadFrame
+			}
+		}
+		if atEOF {
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	}
+	newWriter := func(client *successKinesisClient) *kinesiswriter.Writer {
+		writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+			kinesiswriter.WithKinesisClient(client),
+			kinesiswriter.WithImmediateFlush(),
+			kinesiswriter.WithSplitFunc(split),
+		)
+		require.NoError(t, err)
+		return writer
+	}
+
+	client := &successKinesisClient{}
+	writer := newWriter(client)
+	_, err := writer.Write([]byte("record1|record2|rec!ord3|record4"))
+	var framingErr *kinesiswriter.FramingError
+	require.ErrorAs(t, err, &framingErr)
+	assert.Equal(t, int64(16), framingErr.Offset)
+	assert.ErrorIs(t, err, errBadFrame)
+	require.Len(t, client.Inputs(), 1)
+	assert.Len(t, client.Inputs()[0].Records, 2)
+	require.NoError(t, writer.Close())
+
+	writer = newWriter(&successKinesisClient{})
+	_, err = writer.ReadFrom(strings.NewReader("record1|rec!ord2"))
+	require.ErrorAs(t, err, &framingErr)
+	assert.Equal(t, int64(8), framingErr.Offset)
+	require.NoError(t, writer.Close())
+
+	writer = newWriter(&successKinesisClient{})
+	_, err = writer.ReadFrom(iotest.ErrReader(io.ErrUnexpectedEOF))
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.False(t, errors.As(err, &framingErr))
+	require.NoError(t, writer.Close())
+}
@@ -0,0 +1,172 @@
+package kinesiswriter
+
+import (
+	"errors"
+	"maps"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis/types"
+	"github.com/aws/smithy-go"
+)
+
+const (
+	// unknownErrorCode is used for failed PutRecords calls whose error has no API error code.
+	unknownErrorCode              = "Unknown"
+	provisionedThroughputExceeded = "ProvisionedThroughputExceededException"
+)
+
+// Stats is a snapshot of the delivery counters of a Writer.
+type Stats struct {
+	// RecordsSent is the number of records accepted by Kinesis.
+	RecordsSent int64
+	// RecordsFailed is the number of record delivery failures. A record retried twice counts twice.
+	RecordsFailed int64
+	// FailuresByErrorCode breaks RecordsFailed down by Kinesis error code,
+	// e.g. ProvisionedThroughputExceededException or InternalFailure.
+	FailuresByErrorCode map[string]int64
+	// Shards holds per-shard counters keyed by shard ID.
+	Shards map[string]ShardStats
+	// PayloadUnits is the number of 25KB PUT payload units billed for the accepted records.
+	// Each record is rounded up to a whole unit.
+	PayloadUnits int64
+	// Elapsed is the time since the counters started.
+	Elapsed time.Duration
+	// Tenants holds per-tenant counters keyed by the tenant of WithTenantKey.
+	Tenants map[string]TenantStats
+	// WriterID is the ID of the writer set with WithWriterID, if any, e.g. to label metrics.
+	WriterID string
+}
+
+const (
+	payloadUnitSize = 25 * 1024
+	// DefaultPayloadUnitPrice is the on-demand price in USD of one million PUT payload units
+	// in us-east-1 for provisioned streams.
+	DefaultPayloadUnitPrice = 0.014
+	costMonth               = 30 * 24 * time.Hour
+)
+
+// EstimatedMonthlyCost extrapolates the PUT payload unit cost of the counted period to 30 days,
+// given the price of one million units such as DefaultPayloadUnitPrice.
+// Shard hours are not included. It returns 0 if no time has elapsed.
+func (s Stats) EstimatedMonthlyCost(pricePerMillionUnits float64) float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.PayloadUnits) * float64(costMonth) / float64(s.Elapsed) * pricePerMillionUnits / 1e6
+}
+
+// ShardStats holds delivery counters of a single shard.
+type ShardStats struct {
+	// RecordsSent is the number of records accepted by the shard.
+	RecordsSent int64
+	// BytesSent is the data size of the records accepted by the shard.
+	BytesSent int64
+	// Throttled is the number of records rejected with ProvisionedThroughputExceededException.
+	Throttled int64
+}
+
+type stats struct {
+	mu                  sync.Mutex
+	recordsSent         int64
+	recordsFailed       int64
+	failuresByErrorCode map[string]int64
+	shards              map[string]ShardStats
+	payloadUnits        int64
+	tenants             map[string]TenantStats
+	started             time.Time
+	writerID            string
+}
+
+func newStats(writerID string) *stats {
+	return &stats{
+		writerID:            writerID,
+		failuresByErrorCode: make(map[string]int64),
+		shards:              make(map[string]ShardStats),
+		tenants:             make(map[string]TenantStats),
+		started:             time.Now(),
+	}
+}
+
+// throttledShardID matches the shard ID in throttling error messages such as
+// "Rate exceeded for shard shardId-000000000001 in stream ...".
+var throttledShardID = regexp.MustCompile(`shardId-[0-9]+`)
+
+// result records the outcome of a single PutRecords entry.
+func (s *stats) result(entry types.PutRecordsResultEntry, size int, tenant string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry.ErrorCode == nil {
+		s.recordsSent++
+		if tenant != "" {
+			ts := s.tenants[tenant]
+			ts.RecordsSent++
+			ts.BytesSent += int64(size)
+			s.tenants[tenant] = ts
+		}
+		s.payloadUnits += int64(max(1, (size+payloadUnitSize-1)/payloadUnitSize))
+		if shardID := aws.ToString(entry.ShardId); shardID != "" {
+			ss := s.shards[shardID]
+			ss.RecordsSent++
+			ss.BytesSent += int64(size)
+			s.shards[shardID] = ss
+		}
+		return
+	}
+	s.recordsFailed++
+	s.failuresByErrorCode[*entry.ErrorCode]++
+	if *entry.ErrorCode != provisionedThroughputExceeded {
+		return
+	}
+	if shardID := throttledShardID.FindString(aws.ToString(entry.ErrorMessage)); shardID != "" {
+		ss := s.shards[shardID]
+		ss.Throttled++
+		s.shards[shardID] = ss
+	}
+}
+
+func (s *stats) failed(errorCode string, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recordsFailed += int64(n)
+	s.failuresByErrorCode[errorCode] += int64(n)
+}
+
+func (s *stats) rateLimited(tenant string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ts := s.tenants[tenant]
+	ts.RateLimited++
+	s.tenants[tenant] = ts
+}
+
+func (s *stats) snapshot() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Stats{
+		RecordsSent:         s.recordsSent,
+		RecordsFailed:       s.recordsFailed,
+		FailuresByErrorCode: maps.Clone(s.failuresByErrorCode),
+		Shards:              maps.Clone(s.shards),
+		PayloadUnits:        s.payloadUnits,
+		Tenants:             maps.Clone(s.tenants),
+		Elapsed:             time.Since(s.started),
+		WriterID:            s.writerID,
+	}
+}
+
+// errorCode returns the API error code of err, or unknownErrorCode.
+func errorCode(err error) string {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.ErrorCode()
+	}
+	return unknownErrorCode
+}
+
+// Stats returns a snapshot of the delivery counters.
+func (w *Writer) Stats() Stats {
+	return w.flusher.stats.snapshot()
+}
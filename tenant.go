@@ -0,0 +1,97 @@
+package kinesiswriter
+
+import (
+	"sync"
+	"time"
+)
+
+// TenantStats holds delivery counters of a single tenant.
+type TenantStats struct {
+	// RecordsSent is the number of records of the tenant accepted by Kinesis.
+	RecordsSent int64
+	// BytesSent is the data size of those records.
+	BytesSent int64
+	// RateLimited is the number of records rejected by WithTenantRateLimit.
+	RateLimited int64
+}
+
+// tenantSweepInterval is how often the buckets of idle tenants are evicted.
+const tenantSweepInterval = time.Minute
+
+// tenantLimiter is a token bucket per tenant.
+// Buckets refilled to full are evicted, as a new bucket would be the same.
+type tenantLimiter struct {
+	rate          float64
+	burst         float64
+	sweepInterval time.Duration
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	swept   time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newTenantLimiter(recordsPerSecond float64, burst int) *tenantLimiter {
+	return &tenantLimiter{
+		rate:          recordsPerSecond,
+		burst:         float64(max(burst, 1)),
+		sweepInterval: tenantSweepInterval,
+		buckets:       make(map[string]*tokenBucket),
+		swept:         time.Now(),
+	}
+}
+
+// allow takes a token from the bucket of tenant if one is left.
+func (l *tenantLimiter) allow(tenant string) bool {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.swept) >= l.sweepInterval {
+		l.sweep(now)
+	}
+	b, ok := l.buckets[tenant]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[tenant] = b
+	}
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// sweep evicts the buckets refilled to full by now.
+func (l *tenantLimiter) sweep(now time.Time) {
+	for tenant, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, tenant)
+		}
+	}
+	l.swept = now
+}
+
+// limitTenants passes the records of tenants over their rate limit to the reject handler.
+func (w *Writer) limitTenants(records [][]byte) [][]byte {
+	limiter, key := w.config.tenantLimiter, w.config.tenantKey
+	if limiter == nil || key == nil {
+		return records
+	}
+	allowed := records[:0:0]
+	for _, record := range records {
+		tenant := key(record)
+		if tenant == "" || limiter.allow(tenant) {
+			allowed = append(allowed, record)
+			continue
+		}
+		w.flusher.stats.rateLimited(tenant)
+		w.config.rejectHandler(ErrTenantRateLimited, record)
+	}
+	return allowed
+}
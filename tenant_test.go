@@ -0,0 +1,28 @@
+package kinesiswriter
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenantLimiterEviction(t *testing.T) {
+	l := newTenantLimiter(1000, 1)
+	for i := range 100 {
+		assert.True(t, l.allow(fmt.Sprintf("tenant-%d", i)))
+	}
+	assert.False(t, l.allow("tenant-0"))
+	assert.Len(t, l.buckets, 100)
+
+	time.Sleep(20 * time.Millisecond)
+	l.sweepInterval = 0
+	assert.True(t, l.allow("tenant-0"))
+	assert.Len(t, l.buckets, 1, "refilled buckets are evicted")
+
+	l = newTenantLimiter(0, 1)
+	l.sweepInterval = 0
+	assert.True(t, l.allow("tenant-0"))
+	assert.False(t, l.allow("tenant-0"), "buckets that never refill are kept")
+}
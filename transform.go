@@ -0,0 +1,217 @@
+package kinesiswriter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// Transform converts a record before it is validated and buffered.
+// Records failing to transform go to the reject handler.
+type Transform func(record []byte) ([]byte, error)
+
+// LogfmtToJSON is a Transform converting a logfmt line such as
+// `level=info msg="hello world" ok` into a JSON object such as
+// {"level":"info","msg":"hello world","ok":true}.
+// Values are kept as strings, keys without a value become true
+// and the order of keys is preserved.
+func LogfmtToJSON(record []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	s := record
+	first := true
+	for {
+		s = bytes.TrimLeft(s, " \t")
+		if len(s) == 0 {
+			break
+		}
+		end := bytes.IndexAny(s, "= \t")
+		if end < 0 {
+			end = len(s)
+		}
+		key := s[:end]
+		if len(key) == 0 {
+			return nil, errors.New("logfmt: empty key")
+		}
+		s = s[end:]
+
+		var value []byte
+		if len(s) > 0 && s[0] == '=' {
+			s = s[1:]
+			var err error
+			value, s, err = logfmtValue(s)
+			if err != nil {
+				return nil, fmt.Errorf("logfmt: value of %q: %w", key, err)
+			}
+		} else {
+			value = []byte("true")
+		}
+
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		k, err := json.Marshal(string(key))
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// logfmtValue parses a bare or quoted value at the start of s and returns it as a JSON value
+// with the rest of s.
+func logfmtValue(s []byte) ([]byte, []byte, error) {
+	var str string
+	if len(s) > 0 && s[0] == '"' {
+		end := 1
+		for ; end < len(s) && s[end] != '"'; end++ {
+			if s[end] == '\\' {
+				end++
+			}
+		}
+		if end >= len(s) {
+			return nil, nil, errors.New("unterminated quoted value")
+		}
+		unquoted, err := strconv.Unquote(string(s[:end+1]))
+		if err != nil {
+			return nil, nil, err
+		}
+		str = unquoted
+		s = s[end+1:]
+	} else {
+		end := bytes.IndexAny(s, " \t")
+		if end < 0 {
+			end = len(s)
+		}
+		str = string(s[:end])
+		s = s[end:]
+	}
+	value, err := json.Marshal(str)
+	if err != nil {
+		return nil, nil, err
+	}
+	return value, s, nil
+}
+
+// transform applies the transforms to records, rejecting the records failing to transform.
+func (w *Writer) transform(records [][]byte) [][]byte {
+	transformed := records[:0]
+	for _, record := range records {
+		out, err := w.transformRecord(record)
+		if err != nil {
+			w.config.rejectHandler(err, record)
+			continue
+		}
+		transformed = append(transformed, out)
+	}
+	return transformed
+}
+
+func (w *Writer) transformRecord(record []byte) ([]byte, error) {
+	for _, t := range w.config.transforms {
+		var err error
+		if record, err = t(record); err != nil {
+			return nil, fmt.Errorf("failed to transform record: %w", err)
+		}
+	}
+	return record, nil
+}
+
+// mask replaces masked values.
+const mask = "***"
+
+var (
+	// EmailPattern matches email addresses, for MaskPatterns.
+	EmailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	// CreditCardPattern matches credit card numbers of 13 to 16 digits, optionally grouped
+	// by spaces or hyphens, for MaskPatterns. MaskPatterns masks its matches only if they pass
+	// the Luhn check, so that other numbers such as timestamps are kept.
+	CreditCardPattern = regexp.MustCompile(`\b(?:\d[ -]?){12,15}\d\b`)
+)
+
+// MaskJSONFields returns a Transform replacing the values of the fields named fields
+// with "***" at any depth of JSON records. The keys of the masked records are sorted.
+// Records that are not a single JSON document fail to transform.
+func MaskJSONFields(fields ...string) Transform {
+	masked := make(map[string]struct{}, len(fields))
+	for _, field := range fields {
+		masked[field] = struct{}{}
+	}
+	return func(record []byte) ([]byte, error) {
+		dec := json.NewDecoder(bytes.NewReader(record))
+		dec.UseNumber()
+		var v any
+		if err := dec.Decode(&v); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+		if rest := bytes.TrimSpace(record[dec.InputOffset():]); len(rest) > 0 {
+			return nil, errors.New("invalid JSON: trailing data after JSON document")
+		}
+		return json.Marshal(maskFields(v, masked))
+	}
+}
+
+func maskFields(v any, masked map[string]struct{}) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if _, ok := masked[key]; ok {
+				v[key] = mask
+				continue
+			}
+			v[key] = maskFields(value, masked)
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = maskFields(value, masked)
+		}
+	}
+	return v
+}
+
+// MaskPatterns returns a Transform replacing the matches of patterns in records with "***",
+// e.g. EmailPattern and CreditCardPattern.
+func MaskPatterns(patterns ...*regexp.Regexp) Transform {
+	return func(record []byte) ([]byte, error) {
+		for _, pattern := range patterns {
+			if pattern == CreditCardPattern {
+				record = pattern.ReplaceAllFunc(record, maskCardNumber)
+				continue
+			}
+			record = pattern.ReplaceAllLiteral(record, []byte(mask))
+		}
+		return record, nil
+	}
+}
+
+// maskCardNumber masks match if its digits pass the Luhn check.
+func maskCardNumber(match []byte) []byte {
+	var sum int
+	double := false
+	for i := len(match) - 1; i >= 0; i-- {
+		c := match[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	if sum%10 != 0 {
+		return match
+	}
+	return []byte(mask)
+}
@@ -0,0 +1,116 @@
+package kinesiswriter_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/json"
+	"testing"
+
+	kinesiswriter "github.com/mackee/go-kinesis-writer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogfmtToJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{name: "simple", input: "level=info msg=hello", want: `{"level":"info","msg":"hello"}`},
+		{name: "quoted", input: `msg="hello \"world\"" path=/a`, want: `{"msg":"hello \"world\"","path":"/a"}`},
+		{name: "bare key", input: "level=warn  retry", want: `{"level":"warn","retry":true}`},
+		{name: "empty value", input: "a= b=1", want: `{"a":"","b":"1"}`},
+		{name: "empty", input: "", want: `{}`},
+		{name: "unterminated", input: `msg="hello`, wantErr: true},
+		{name: "empty key", input: "=x", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := kinesiswriter.LogfmtToJSON([]byte(tt.input))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestWriterTransform(t *testing.T) {
+	client := &successKinesisClient{}
+	var rejected []string
+	writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithImmediateFlush(),
+		kinesiswriter.WithTransform(kinesiswriter.LogfmtToJSON),
+		kinesiswriter.WithRejectHandler(func(err error, record []byte) {
+			rejected = append(rejected, string(record))
+		}),
+	)
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("level=info msg=\"started\"\nmsg=\"broken\n"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	assert.Equal(t, []string{`msg="broken`}, rejected)
+	require.Len(t, client.Inputs(), 1)
+	require.Len(t, client.Inputs()[0].Records, 1)
+	assert.Equal(t, `{"level":"info","msg":"started"}`, string(client.Inputs()[0].Records[0].Data))
+}
+
+func TestMaskJSONFields(t *testing.T) {
+	transform := kinesiswriter.MaskJSONFields("email", "card")
+	got, err := transform([]byte(`{"user":{"email":"a@example.com","id":12345678901234567890},"items":[{"card":"4111"}],"level":"info"}`))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"user":{"email":"***","id":12345678901234567890},"items":[{"card":"***"}],"level":"info"}`, string(got))
+	assert.Contains(t, string(got), "12345678901234567890", "numbers are kept as they are")
+
+	_, err = transform([]byte("not json"))
+	assert.Error(t, err)
+	_, err = transform([]byte(`{"email":"a@example.com"} {"email":"b@example.com"}`))
+	assert.Error(t, err)
+}
+
+func TestMaskPatterns(t *testing.T) {
+	transform := kinesiswriter.MaskPatterns(kinesiswriter.EmailPattern, kinesiswriter.CreditCardPattern)
+	got, err := transform([]byte("user a.b+c@example.co.jp paid with 4111 1111 1111 1111 for order 42"))
+	require.NoError(t, err)
+	assert.Equal(t, "user *** paid with *** for order 42", string(got))
+
+	got, err = transform([]byte(`{"ts":1760486400000,"card":"4111-1111-1111-1111"}`))
+	require.NoError(t, err)
+	assert.Equal(t, `{"ts":1760486400000,"card":"***"}`, string(got), "timestamps failing the Luhn check are kept")
+}
+
+func TestEncryptJSONFields(t *testing.T) {
+	block, err := aes.NewCipher(bytes.Repeat([]byte{1}, 32))
+	require.NoError(t, err)
+	aead, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+
+	transform := kinesiswriter.EncryptJSONFields(aead, "payload", "missing")
+	got, err := transform([]byte(`{"tenant":"t1","payload":{"ssn":"123-45-6789"}}`))
+	require.NoError(t, err)
+
+	var obj map[string]string
+	require.NoError(t, json.Unmarshal(got, &obj))
+	assert.Equal(t, "t1", obj["tenant"])
+	assert.NotContains(t, string(got), "123-45-6789")
+
+	plain, err := kinesiswriter.DecryptJSONField(aead, "payload", obj["payload"])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"ssn":"123-45-6789"}`, string(plain))
+
+	_, err = kinesiswriter.DecryptJSONField(aead, "tenant", obj["payload"])
+	assert.Error(t, err, "the ciphertext is bound to the field")
+
+	for _, record := range []string{`["not an object"]`, `null`, `{"payload":1} {"payload":2}`} {
+		_, err = transform([]byte(record))
+		assert.Error(t, err, record)
+	}
+}
@@ -0,0 +1,147 @@
+package kinesiswriter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// RecordValidator checks a record before it is buffered.
+// Records failing validation are passed to the reject handler instead of being sent.
+type RecordValidator func(record []byte) error
+
+// RejectHandler handles a record that failed validation.
+type RejectHandler func(err error, record []byte)
+
+// compileJSONSchema returns a RecordValidator validating records against schema.
+func compileJSONSchema(schema string) (RecordValidator, error) {
+	sch, err := jsonschema.CompileString("schema.json", schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile JSON schema: %w", err)
+	}
+	return func(record []byte) error {
+		dec := json.NewDecoder(bytes.NewReader(record))
+		dec.UseNumber()
+		var v any
+		if err := dec.Decode(&v); err != nil {
+			return fmt.Errorf("invalid JSON: %w", err)
+		}
+		if err := sch.Validate(v); err != nil {
+			return err
+		}
+		return nil
+	}, nil
+}
+
+// NDJSONError reports a record that is not a single JSON document on one line.
+type NDJSONError struct {
+	// Offset is the byte offset of the violation within the record.
+	Offset int64
+	Err    error
+}
+
+func (e *NDJSONError) Error() string {
+	return fmt.Sprintf("invalid NDJSON at byte %d: %s", e.Offset, e.Err)
+}
+
+func (e *NDJSONError) Unwrap() error {
+	return e.Err
+}
+
+// ValidateNDJSON is a RecordValidator checking that record is exactly one JSON document
+// without embedded newlines. It returns an *NDJSONError.
+func ValidateNDJSON(record []byte) error {
+	if i := bytes.IndexByte(record, '\n'); i >= 0 {
+		return &NDJSONError{Offset: int64(i), Err: errors.New("embedded newline")}
+	}
+	dec := json.NewDecoder(bytes.NewReader(record))
+	var v json.RawMessage
+	if err := dec.Decode(&v); err != nil {
+		offset := dec.InputOffset()
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			offset = syntaxErr.Offset
+		}
+		return &NDJSONError{Offset: offset, Err: err}
+	}
+	if rest := bytes.TrimLeft(record[dec.InputOffset():], " \t\r"); len(rest) > 0 {
+		return &NDJSONError{Offset: int64(len(record) - len(rest)), Err: errors.New("trailing data after JSON document")}
+	}
+	return nil
+}
+
+// validate returns the records passing all validators and rejects the others.
+func (w *Writer) validate(records [][]byte) [][]byte {
+	valid := records[:0]
+	for _, record := range records {
+		if err := w.validateRecord(record); err != nil {
+			w.config.rejectHandler(err, record)
+			continue
+		}
+		valid = append(valid, record)
+	}
+	return valid
+}
+
+func (w *Writer) validateRecord(record []byte) error {
+	for _, validate := range w.config.validators {
+		if err := validate(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OversizePolicy is how records over the 1MiB Kinesis record size limit are handled.
+// The limit leaves room for the longest partition key, as Kinesis counts it in the record size.
+type OversizePolicy int
+
+const (
+	// OversizeError passes oversized records to the reject handler with ErrRecordTooLarge.
+	// This is the default.
+	OversizeError OversizePolicy = iota
+	// OversizeTruncate cuts oversized records at the limit, ending them with "[truncated]".
+	OversizeTruncate
+	// OversizeChunk splits oversized records into records of the size limit.
+	OversizeChunk
+	// OversizeDrop drops oversized records silently.
+	OversizeDrop
+)
+
+const truncationMarker = "[truncated]"
+
+// limitSize applies the oversize policy to the records over the size limit.
+// The partition key is chosen at flush and counts in the record size,
+// so the limit leaves room for the longest one.
+func (w *Writer) limitSize(records [][]byte) [][]byte {
+	const limit = maxRecordSize - maxPartitionKeySize
+	if !slices.ContainsFunc(records, func(record []byte) bool { return len(record) > limit }) {
+		return records
+	}
+	limited := make([][]byte, 0, len(records))
+	for _, record := range records {
+		if len(record) <= limit {
+			limited = append(limited, record)
+			continue
+		}
+		switch w.config.oversizePolicy {
+		case OversizeTruncate:
+			truncated := append(record[:limit-len(truncationMarker):limit-len(truncationMarker)], truncationMarker...)
+			limited = append(limited, truncated)
+		case OversizeChunk:
+			for len(record) > 0 {
+				n := min(len(record), limit)
+				limited = append(limited, record[:n:n])
+				record = record[n:]
+			}
+		case OversizeDrop:
+		default:
+			w.config.rejectHandler(fmt.Errorf("record of %d bytes: %w", len(record), ErrRecordTooLarge), record)
+		}
+	}
+	return limited
+}
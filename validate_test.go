@@ -0,0 +1,176 @@
+package kinesiswriter_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis"
+	kinesiswriter "github.com/mackee/go-kinesis-writer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterJSONSchema(t *testing.T) {
+	ctx := context.Background()
+	client := &successKinesisClient{}
+	var rejected []string
+	writer, err := kinesiswriter.New(ctx, testStreamARN,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithImmediateFlush(),
+		kinesiswriter.WithJSONSchema(`{
+			"type": "object",
+			"required": ["level"],
+			"properties": {"level": {"type": "string"}}
+		}`),
+		kinesiswriter.WithRejectHandler(func(err error, record []byte) {
+			assert.Error(t, err)
+			rejected = append(rejected, string(record))
+		}),
+	)
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("{\"level\":\"info\"}\n{\"level\":1}\nnot json\n{\"msg\":\"x\"}"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	assert.Equal(t, []string{`{"level":1}`, "not json", `{"msg":"x"}`}, rejected)
+	require.Len(t, client.Inputs(), 1)
+	require.Len(t, client.Inputs()[0].Records, 1)
+	assert.Equal(t, `{"level":"info"}`, string(client.Inputs()[0].Records[0].Data))
+
+	_, err = kinesiswriter.New(ctx, testStreamARN,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithJSONSchema(`{"type": 1}`),
+	)
+	assert.Error(t, err)
+}
+
+func TestValidateNDJSON(t *testing.T) {
+	tests := []struct {
+		name       string
+		record     string
+		wantOffset int64
+		wantErr    bool
+	}{
+		{name: "object", record: `{"a":1}`},
+		{name: "trailing space", record: `{"a":1}  `},
+		{name: "embedded newline", record: "{\"a\":\n1}", wantOffset: 5, wantErr: true},
+		{name: "syntax error", record: `{"a":x}`, wantOffset: 6, wantErr: true},
+		{name: "two documents", record: `{"a":1} {"b":2}`, wantOffset: 8, wantErr: true},
+		{name: "empty", record: "", wantOffset: 0, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := kinesiswriter.ValidateNDJSON([]byte(tt.record))
+			if !tt.wantErr {
+				assert.NoError(t, err)
+				return
+			}
+			var ndjsonErr *kinesiswriter.NDJSONError
+			require.ErrorAs(t, err, &ndjsonErr)
+			assert.Equal(t, tt.wantOffset, ndjsonErr.Offset)
+		})
+	}
+}
+
+// recordSizeKinesisClient checks that records fit the Kinesis record size limit with their keys.
+type recordSizeKinesisClient struct {
+	successKinesisClient
+	t *testing.T
+}
+
+func (c *recordSizeKinesisClient) PutRecords(ctx context.Context, params *kinesis.PutRecordsInput, optFns ...func(*kinesis.Options)) (*kinesis.PutRecordsOutput, error) {
+	for _, entry := range params.Records {
+		assert.LessOrEqual(c.t, len(entry.Data)+len(aws.ToString(entry.PartitionKey)), 1<<20)
+	}
+	return c.successKinesisClient.PutRecords(ctx, params, optFns...)
+}
+
+func TestWriterOversizePolicy(t *testing.T) {
+	const limit = 1024*1024 - 256
+	record := bytes.Repeat([]byte("a"), limit+261)
+	tests := []struct {
+		name     string
+		policy   kinesiswriter.OversizePolicy
+		sizes    []int
+		rejected int
+	}{
+		{name: "error", policy: kinesiswriter.OversizeError, rejected: 1},
+		{name: "truncate", policy: kinesiswriter.OversizeTruncate, sizes: []int{limit}},
+		{name: "chunk", policy: kinesiswriter.OversizeChunk, sizes: []int{limit, 261}},
+		{name: "drop", policy: kinesiswriter.OversizeDrop},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &recordSizeKinesisClient{t: t}
+			var rejected int
+			writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+				kinesiswriter.WithKinesisClient(client),
+				kinesiswriter.WithImmediateFlush(),
+				kinesiswriter.WithOversizePolicy(tt.policy),
+				kinesiswriter.WithPartitioner(kinesiswriter.StaticPartitioner(strings.Repeat("k", 256))),
+				kinesiswriter.WithRejectHandler(func(err error, record []byte) {
+					assert.ErrorIs(t, err, kinesiswriter.ErrRecordTooLarge)
+					rejected++
+				}),
+			)
+			require.NoError(t, err)
+			require.NoError(t, writer.WriteRecordWithPriority(record, kinesiswriter.PriorityNormal))
+			require.NoError(t, writer.Close())
+
+			var sizes []int
+			for _, input := range client.Inputs() {
+				for _, entry := range input.Records {
+					sizes = append(sizes, len(entry.Data))
+				}
+			}
+			assert.Equal(t, tt.sizes, sizes)
+			assert.Equal(t, tt.rejected, rejected)
+			if tt.policy == kinesiswriter.OversizeTruncate {
+				assert.True(t, bytes.HasSuffix(client.Inputs()[0].Records[0].Data, []byte("[truncated]")))
+			}
+		})
+	}
+}
+
+func TestWriterOversizePolicyReaders(t *testing.T) {
+	const limit = 1024*1024 - 256
+	input := "small\n" + strings.Repeat("a", 2*limit+5) + "\n"
+	tests := []struct {
+		name string
+		read func(w *kinesiswriter.Writer) error
+	}{
+		{name: "ReadFrom", read: func(w *kinesiswriter.Writer) error {
+			_, err := w.ReadFrom(strings.NewReader(input))
+			return err
+		}},
+		{name: "Backfill", read: func(w *kinesiswriter.Writer) error {
+			progress, err := w.Backfill(context.Background(), strings.NewReader(input))
+			assert.Equal(t, int64(len(input)), progress.Offset)
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &recordSizeKinesisClient{t: t}
+			writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+				kinesiswriter.WithKinesisClient(client),
+				kinesiswriter.WithImmediateFlush(),
+				kinesiswriter.WithOversizePolicy(kinesiswriter.OversizeChunk),
+			)
+			require.NoError(t, err)
+			require.NoError(t, tt.read(writer))
+			require.NoError(t, writer.Close())
+
+			var sizes []int
+			for _, input := range client.Inputs() {
+				for _, entry := range input.Records {
+					sizes = append(sizes, len(entry.Data))
+				}
+			}
+			assert.Equal(t, []int{5, limit, limit, 5}, sizes)
+		})
+	}
+}
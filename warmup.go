@@ -0,0 +1,41 @@
+package kinesiswriter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis/types"
+)
+
+// KinesisStreamDescriber is implemented by Kinesis clients that can describe a stream.
+// *kinesis.Client implements it.
+type KinesisStreamDescriber interface {
+	DescribeStreamSummary(ctx context.Context, params *kinesis.DescribeStreamSummaryInput, optFns ...func(*kinesis.Options)) (*kinesis.DescribeStreamSummaryOutput, error)
+}
+
+// warmUp describes the streams of the writer, failing unless they accept records.
+func warmUp(ctx context.Context, client KinesisClient, streamARNs []string) error {
+	describer, ok := client.(KinesisStreamDescriber)
+	if !ok {
+		return errors.New("warm up requires a client implementing KinesisStreamDescriber")
+	}
+	for _, streamARN := range streamARNs {
+		out, err := describer.DescribeStreamSummary(ctx, &kinesis.DescribeStreamSummaryInput{
+			StreamARN: aws.String(streamARN),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to warm up %s: %w", streamARN, err)
+		}
+		var status types.StreamStatus
+		if out.StreamDescriptionSummary != nil {
+			status = out.StreamDescriptionSummary.StreamStatus
+		}
+		if status != types.StreamStatusActive && status != types.StreamStatusUpdating {
+			return fmt.Errorf("failed to warm up %s: stream is %s", streamARN, status)
+		}
+	}
+	return nil
+}
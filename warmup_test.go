@@ -0,0 +1,78 @@
+package kinesiswriter_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis/types"
+	"github.com/aws/smithy-go"
+	kinesiswriter "github.com/mackee/go-kinesis-writer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type describingKinesisClient struct {
+	successKinesisClient
+	statuses  map[string]types.StreamStatus
+	described []string
+}
+
+func (c *describingKinesisClient) DescribeStreamSummary(ctx context.Context, params *kinesis.DescribeStreamSummaryInput, optFns ...func(*kinesis.Options)) (*kinesis.DescribeStreamSummaryOutput, error) {
+	streamARN := aws.ToString(params.StreamARN)
+	c.described = append(c.described, streamARN)
+	status, ok := c.statuses[streamARN]
+	if !ok {
+		return nil, &smithy.GenericAPIError{Code: "ResourceNotFoundException"}
+	}
+	return &kinesis.DescribeStreamSummaryOutput{
+		StreamDescriptionSummary: &types.StreamDescriptionSummary{StreamARN: params.StreamARN, StreamStatus: status},
+	}, nil
+}
+
+func TestWriterWarmup(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses map[string]types.StreamStatus
+		wantErr  string
+	}{
+		{
+			name:     "active",
+			statuses: map[string]types.StreamStatus{stream1ARN: types.StreamStatusActive, stream2ARN: types.StreamStatusUpdating},
+		},
+		{
+			name:     "creating",
+			statuses: map[string]types.StreamStatus{stream1ARN: types.StreamStatusActive, stream2ARN: types.StreamStatusCreating},
+			wantErr:  "stream is CREATING",
+		},
+		{
+			name:     "not found",
+			statuses: map[string]types.StreamStatus{stream2ARN: types.StreamStatusActive},
+			wantErr:  "ResourceNotFoundException",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &describingKinesisClient{statuses: tt.statuses}
+			writer, err := kinesiswriter.New(context.Background(), stream1ARN,
+				kinesiswriter.WithKinesisClient(client),
+				kinesiswriter.WithStreamKeyFunc(func([]byte) string { return "" }, map[string]string{"other": stream2ARN}),
+				kinesiswriter.WithWarmup(),
+			)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, []string{stream1ARN, stream2ARN}, client.described)
+			require.NoError(t, writer.Close())
+		})
+	}
+
+	_, err := kinesiswriter.New(context.Background(), stream1ARN,
+		kinesiswriter.WithKinesisClient(&successKinesisClient{}),
+		kinesiswriter.WithWarmup(),
+	)
+	assert.Error(t, err)
+}
@@ -3,86 +3,765 @@ package kinesiswriter
 import (
 	"bufio"
 	"bytes"
+	"cmp"
+	"compress/gzip"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/kinesis"
 	buffer "github.com/woorui/async-buffer"
 )
 
+// waitPollInterval is how often Wait checks for pending records.
+const waitPollInterval = 10 * time.Millisecond
+
 // Writer writes records to a Kinesis stream.
+// Its methods are safe for concurrent use; the records of concurrent Writes may interleave.
 type Writer struct {
 	ctx           context.Context
 	config        *writerConfig
-	kinesisBuffer *buffer.Buffer[[]byte]
+	streamARN     StreamARN
+	flusher       *flusher
+	kinesisBuffer Buffer
+	// highBuffer holds the records of PriorityHigh.
+	highBuffer Buffer
+	teeMu      sync.Mutex
+	archiver   *archiver
+	reporter   *periodic
+	heartbeat  *periodic
+	canary     *periodic
+	announcer  *periodic
+	partialMu  sync.Mutex
+	// partial is the data held after the last newline by WithHoldPartialLine.
+	partial []byte
+	closed  atomic.Bool
+	// shutdown is set by Shutdown to refuse new records while the buffers drain.
+	shutdown atomic.Bool
+	// closeMu is held for reading while records are written, so that Close does not
+	// close the archiver and the buffers under a concurrent Write.
+	closeMu sync.RWMutex
+	// routes are the streams of WithStreamKeyFunc other than the default one.
+	routes *streamRoutes
+	// opts are the options the writer was created with, for Clone.
+	opts []WriterConfigOption
 }
 
 // New creates a new Writer.
+// It returns an *InvalidARNError if streamARN is not a Kinesis stream ARN.
 func New(ctx context.Context, streamARN string, opts ...WriterConfigOption) (*Writer, error) {
+	parsedARN, err := ParseStreamARN(streamARN)
+	if err != nil {
+		return nil, err
+	}
+	conf, err := newWriterConfig(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if conf.streamARN != "" {
+		streamARN = conf.streamARN
+		if parsedARN, err = ParseStreamARN(streamARN); err != nil {
+			return nil, err
+		}
+	}
+	fl, err := newFlusher(conf, streamARN)
+	if err != nil {
+		return nil, err
+	}
+	if conf.warmup {
+		streamARNs := []string{streamARN}
+		for _, s := range conf.streams {
+			streamARNs = append(streamARNs, s)
+		}
+		slices.Sort(streamARNs[1:])
+		if err := warmUp(ctx, conf.client, streamARNs); err != nil {
+			return nil, err
+		}
+	}
+	bufferFlushTimeout := conf.bufferConfig.flushTimeout
+	if conf.preserveOrder {
+		// the buffer must wait for every flush to finish instead of starting the next one.
+		bufferFlushTimeout = 0
+	}
+
+	w := &Writer{
+		config:        conf,
+		streamARN:     parsedARN,
+		flusher:       fl,
+		kinesisBuffer: newBuffer(fl, conf, bufferFlushTimeout),
+		highBuffer:    newBuffer(fl, conf, bufferFlushTimeout),
+		opts:          slices.Concat(opts, []WriterConfigOption{WithKinesisClient(conf.client)}),
+	}
+	if conf.streamKey != nil {
+		if w.routes, err = newStreamRoutes(conf, fl, bufferFlushTimeout); err != nil {
+			for _, buf := range w.buffers() {
+				_ = buf.Close()
+			}
+			return nil, err
+		}
+	}
+	if conf.archive != nil {
+		w.archiver = newArchiver(conf.archive.uploader, conf.archive.prefix, conf.archive.interval)
+	}
+	if conf.statsReport != nil {
+		report := conf.statsReport.report
+		w.reporter = startPeriodic(conf.statsReport.interval, func() {
+			report(fl.stats.snapshot())
+		}, true)
+	}
+	if conf.canary != nil {
+		w.canary = startPeriodic(conf.canaryInterval, func() {
+			if err := w.enqueue(newRecords([][]byte{conf.canary.record()}), normalPriority); err != nil {
+				log.Printf("failed to write canary: %s", err)
+			}
+		}, false)
+	}
+	if conf.announce {
+		announce := func() {
+			if err := w.enqueue(newRecords([][]byte{conf.encodingAnnouncement()}), highPriority); err != nil {
+				log.Printf("failed to write encoding announcement: %s", err)
+			}
+		}
+		announce()
+		w.announcer = startPeriodic(conf.announceInterval, announce, false)
+	}
+	if conf.heartbeat != nil {
+		payload := conf.heartbeat.payload
+		w.heartbeat = startPeriodic(conf.heartbeat.interval, func() {
+			if err := w.enqueue(newRecords([][]byte{payload()}), highPriority); err != nil {
+				log.Printf("failed to write heartbeat: %s", err)
+			}
+		}, false)
+	}
+	if conf.manager != nil {
+		if err := conf.manager.add(w); err != nil {
+			_ = w.Close()
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// Clone creates a new Writer with the options of w followed by opts, sharing its Kinesis client.
+// Use WithStreamARN to send to another stream.
+func (w *Writer) Clone(opts ...WriterConfigOption) (*Writer, error) {
+	return New(context.Background(), w.streamARN.String(), slices.Concat(w.opts, opts)...)
+}
+
+// newWriterConfig applies opts to the default configuration.
+func newWriterConfig(ctx context.Context, opts []WriterConfigOption) (*writerConfig, error) {
 	conf := &writerConfig{
-		splitFunc: bufio.ScanLines,
+		splitFunc:       bufio.ScanLines,
+		scanLines:       true,
+		failureWindow:   defaultFailureWindow,
+		redactor:        redactNone,
+		priorityFunc:    normalPriority,
+		codec:           JSONCodec,
+		codecName:       "json",
+		permanentErrors: make(map[string]struct{}, len(defaultPermanentErrorCodes)),
 		bufferConfig: &bufferConfig{
 			recordWindow:  defaultBufferRecordWindow,
 			writeTimeout:  defaultBufferWriteTimeout,
 			flushTimeout:  defaultBufferFlushTimeout,
 			flushInterval: defaultBufferFlushInterval,
-			errorHandler:  defaultBufferErrorHandler,
 		},
 	}
 
+	for _, code := range defaultPermanentErrorCodes {
+		conf.permanentErrors[code] = struct{}{}
+	}
+
 	for _, opt := range opts {
 		opt(conf)
 	}
+	if conf.codecNameErr != nil {
+		return nil, conf.codecNameErr
+	}
+	if conf.profileErr != nil {
+		return nil, conf.profileErr
+	}
+	if err := conf.validateIntervals(); err != nil {
+		return nil, err
+	}
+	if conf.writerIDGenerator != nil {
+		conf.writerID = conf.writerIDGenerator()
+	}
+	out := conf.defaultOutput()
+	if conf.rejectHandler == nil {
+		conf.rejectHandler = out.reject
+	}
+	if conf.bufferConfig.errorHandler == nil {
+		conf.bufferConfig.errorHandler = out.bufferError
+	}
+	if d := conf.writeDeadline; d > 0 && (conf.bufferConfig.writeTimeout <= 0 || conf.bufferConfig.writeTimeout > d) {
+		conf.bufferConfig.writeTimeout = d
+	}
+	if conf.jsonSchema != "" {
+		validator, err := compileJSONSchema(conf.jsonSchema)
+		if err != nil {
+			return nil, err
+		}
+		conf.validators = append(slices.Clip(conf.validators), validator)
+	}
 	if conf.client == nil {
-		awsConfig, err := config.LoadDefaultConfig(ctx)
+		client, err := newDefaultClient(ctx, conf.adaptiveRetry)
 		if err != nil {
-			return nil, fmt.Errorf("failed to load AWS config: %w", err)
+			return nil, err
+		}
+		conf.client = client
+	}
+
+	return conf, nil
+}
+
+// validateIntervals checks the intervals of the periodic tasks, which panic on non-positive ones.
+func (c *writerConfig) validateIntervals() error {
+	if c.heartbeat != nil && c.heartbeat.interval <= 0 {
+		return fmt.Errorf("invalid heartbeat interval %s: must be positive", c.heartbeat.interval)
+	}
+	if c.statsReport != nil && c.statsReport.interval <= 0 {
+		return fmt.Errorf("invalid stats report interval %s: must be positive", c.statsReport.interval)
+	}
+	if c.canary != nil && c.canaryInterval <= 0 {
+		return fmt.Errorf("invalid canary interval %s: must be positive", c.canaryInterval)
+	}
+	if c.announce && c.announceInterval <= 0 {
+		return fmt.Errorf("invalid encoding announcement interval %s: must be positive", c.announceInterval)
+	}
+	return nil
+}
+
+// newFlusher creates the flusher delivering records to streamARN as configured by conf.
+func newFlusher(conf *writerConfig, streamARN string) (*flusher, error) {
+	var part Partitioner = randomPartitioner{}
+	if conf.partitionKeys != nil {
+		part = conf.partitionKeys
+	}
+	if conf.tenantKey != nil {
+		part = ContentPartitioner(conf.tenantKey, part)
+	}
+	var shardMap *roundRobinPartitioner
+	if conf.roundRobinHashKey {
+		lister, ok := conf.client.(KinesisShardLister)
+		if !ok {
+			return nil, errors.New("round robin hash keys require a client implementing KinesisShardLister")
+		}
+		shardMap = &roundRobinPartitioner{
+			keys:            part,
+			lister:          lister,
+			streamARN:       streamARN,
+			refreshInterval: defaultShardRefreshInterval,
+		}
+		part = shardMap
+	}
+
+	scaleOut, err := newScaleOutDetector(conf, streamARN)
+	if err != nil {
+		return nil, err
+	}
+
+	middlewares := conf.middlewares
+	if conf.requestLogger != nil {
+		logger := conf.requestLogger
+		if conf.writerID != "" {
+			logger = logger.With(slog.String("writer_id", conf.writerID))
 		}
-		conf.client = kinesis.NewFromConfig(awsConfig)
+		// innermost, to log what is actually sent to the client.
+		middlewares = append(slices.Clip(middlewares), requestLogMiddleware(logger))
 	}
 
+	flushDeadline := conf.flushDeadline
+	if flushDeadline == 0 {
+		flushDeadline = conf.bufferConfig.flushTimeout
+	}
 	fl := &flusher{
-		client:       conf.client,
-		streamARN:    streamARN,
-		flushTimeout: conf.bufferConfig.flushTimeout,
-	}
-	kb := buffer.New(fl, buffer.Option[[]byte]{
-		Threshold:     conf.bufferConfig.recordWindow,
-		WriteTimeout:  conf.bufferConfig.writeTimeout,
-		FlushTimeout:  conf.bufferConfig.flushTimeout,
-		FlushInterval: conf.bufferConfig.flushInterval,
-		ErrHandler:    conf.bufferConfig.errorHandler,
-	})
-
-	return &Writer{
-		config:        conf,
-		kinesisBuffer: kb,
-	}, nil
+		putRecords:        chainPutRecords(conf.client, middlewares),
+		streamARN:         streamARN,
+		flushDeadline:     flushDeadline,
+		putRecordsTimeout: conf.putRecordsTimeout,
+		resultHook:        conf.resultHook,
+		flushHook:         conf.flushHook,
+		health:            newHealth(conf.bufferConfig.recordWindow, conf.failureWindow),
+		slowFlush:         conf.slowFlush,
+		retrier:           conf.retrier,
+		sdkRetriesCalls:   conf.adaptiveRetry,
+		errorHandler:      conf.bufferConfig.errorHandler,
+		closePolicy:       conf.closePolicy,
+		canary:            conf.canary,
+		permanentErrors:   conf.permanentErrors,
+		stats:             newStats(conf.writerID),
+		writerID:          conf.writerID,
+		scaleOut:          scaleOut,
+		tenantKey:         conf.tenantKey,
+		fatalCh:           conf.fatalCh,
+		partitioner:       part,
+		shardMap:          shardMap,
+		auditSink:         conf.auditSink,
+		latency:           conf.latency,
+		mutationGuard:     conf.mutationGuard,
+	}
+	if conf.manifest {
+		manifestStream := cmp.Or(conf.manifestStream, streamARN)
+		if _, err := ParseStreamARN(manifestStream); err != nil {
+			return nil, fmt.Errorf("invalid manifest stream: %w", err)
+		}
+		fl.manifests = &batchManifests{streamARN: manifestStream}
+	}
+	if conf.hotShard != nil {
+		var shardCount func() int
+		if shardMap != nil {
+			shardCount = shardMap.shardCount
+		} else if lister, ok := conf.client.(KinesisShardLister); ok {
+			counter := &shardCounter{lister: lister, streamARN: streamARN, refreshInterval: defaultShardRefreshInterval}
+			counter.get()
+			shardCount = counter.get
+		}
+		fl.hotShard = newHotShardDetector(*conf.hotShard, shardCount)
+	}
+	if conf.advisorSample > 0 {
+		fl.advisor = newBatchAdvisor(conf.advisorSample, *conf.bufferConfig, conf.maxInFlight)
+	}
+	if conf.maxInFlight > 0 {
+		fl.inFlight = make(chan struct{}, conf.maxInFlight)
+	}
+	if fl.retrier == nil {
+		fl.retrier = &backoffRetrier{maxRetries: retryMaxCount, backoffs: conf.retryBackoffs}
+	}
+	if conf.preserveOrder {
+		fl.orderMu = &sync.Mutex{}
+	}
+	return fl, nil
+}
+
+func newBuffer(fl *flusher, conf *writerConfig, flushTimeout time.Duration) Buffer {
+	flush := bufferFlusher{flusher: fl}.Flush
+	if conf.manager != nil {
+		return conf.manager.newBuffer(flush, *conf.bufferConfig)
+	}
+	if conf.newBuffer != nil {
+		return conf.newBuffer(flush)
+	}
+	return newAsyncBuffer(flush, conf.bufferConfig, flushTimeout)
+}
+
+// StreamARN returns the parsed ARN of the stream the writer sends records to.
+func (w *Writer) StreamARN() StreamARN {
+	return w.streamARN
+}
+
+// ID returns the ID of the writer set with WithWriterID or WithWriterIDGenerator, if any.
+func (w *Writer) ID() string {
+	return w.config.writerID
+}
+
+// newDefaultClient creates a Kinesis client from the default AWS config,
+// using the adaptive retry mode of the SDK if adaptiveRetry is set.
+func newDefaultClient(ctx context.Context, adaptiveRetry bool) (*kinesis.Client, error) {
+	awsConfig, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	return kinesis.NewFromConfig(awsConfig, func(o *kinesis.Options) {
+		if adaptiveRetry {
+			o.Retryer = retry.NewAdaptiveMode()
+		}
+	}), nil
 }
 
 func (w *Writer) Write(p []byte) (int, error) {
+	n := len(p)
+	if w.config.holdPartialLine {
+		if w.stopped() {
+			// do not hold data that could not be written anymore.
+			return 0, ErrWriterClosed
+		}
+		p = w.completeLines(p)
+	}
+	if err := w.write(p); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// completeLines returns the complete lines of the data held from the previous writes and p,
+// and holds the rest.
+func (w *Writer) completeLines(p []byte) []byte {
+	w.partialMu.Lock()
+	defer w.partialMu.Unlock()
+	data := append(w.partial, p...)
+	i := bytes.LastIndexByte(data, '\n')
+	w.partial = bytes.Clone(data[i+1:])
+	return data[:i+1]
+}
+
+// write splits p into records and writes them.
+func (w *Writer) write(p []byte) error {
+	if record, ok := w.singleLine(p); ok {
+		return w.writeRecords([][]byte{record}, w.config.priorityFunc)
+	}
+	split := &framingSplit{split: w.config.splitFunc}
 	scanner := bufio.NewScanner(bytes.NewReader(p))
-	scanner.Split(w.config.splitFunc)
+	scanner.Split(split.scan)
+	// p is already in memory, so a record may be as large as p and is checked later.
+	scanner.Buffer(nil, len(p)+1)
 
+	var records [][]byte
 	for scanner.Scan() {
-		line := scanner.Bytes()
-		if _, err := w.kinesisBuffer.Write(line); err != nil {
-			return 0, fmt.Errorf("failed to write to buffer: %w", err)
+		records = append(records, bytes.Clone(scanner.Bytes()))
+	}
+	if err := w.writeRecords(records, w.config.priorityFunc); err != nil {
+		return err
+	}
+	return split.framingError()
+}
+
+// singleLine returns a copy of p as a single record without scanning it when records are lines
+// and p holds at most one line, as written by most loggers. ok is false otherwise.
+func (w *Writer) singleLine(p []byte) ([]byte, bool) {
+	if !w.config.scanLines || len(p) == 0 {
+		return nil, false
+	}
+	line := p
+	if i := bytes.IndexByte(p, '\n'); i >= 0 {
+		if i != len(p)-1 {
+			return nil, false
+		}
+		line = p[:i]
+	}
+	return bytes.Clone(dropCR(line)), true
+}
+
+// ReadFrom reads records from r until EOF and writes them. Unlike Write,
+// records spanning several reads of r are kept whole. Gzip-compressed input
+// is detected and decompressed on the fly. Oversized records are handled by the
+// oversize policy, but reading stops with ErrRecordTooLarge at a record over 64MiB.
+// It implements io.ReaderFrom.
+func (w *Writer) ReadFrom(r io.Reader) (int64, error) {
+	cr := &countingReader{r: r}
+	src, err := maybeGunzip(cr)
+	if err != nil {
+		return cr.n, err
+	}
+	split := &framingSplit{split: w.config.splitFunc}
+	scanner := bufio.NewScanner(src)
+	scanner.Split(split.scan)
+	scanner.Buffer(nil, maxOversizeRecordSize)
+
+	for scanner.Scan() {
+		if err := w.writeRecords([][]byte{bytes.Clone(scanner.Bytes())}, w.config.priorityFunc); err != nil {
+			return cr.n, err
+		}
+	}
+	if err := split.framingError(); err != nil {
+		return cr.n, fmt.Errorf("failed to read records: %w", err)
+	}
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return cr.n, fmt.Errorf("failed to read records: %w: %w", ErrRecordTooLarge, err)
 		}
+		return cr.n, fmt.Errorf("failed to read records: %w", err)
+	}
+	return cr.n, nil
+}
+
+// maybeGunzip returns a reader decompressing r if it starts with the gzip magic number.
+func maybeGunzip(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err != nil || magic[0] != 0x1f || magic[1] != 0x8b {
+		// let the scanner see a short input and the read error as they are.
+		return br, nil
+	}
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read gzip header: %w", err)
+	}
+	return zr, nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
 
+// writeRecords transforms and checks records, and enqueues them.
+func (w *Writer) writeRecords(records [][]byte, priority func(record []byte) Priority) error {
+	w.closeMu.RLock()
+	defer w.closeMu.RUnlock()
+	if w.stopped() {
+		return ErrWriterClosed
 	}
-	return len(p), nil
+	records = w.prepare(records)
+	if w.config.tee != nil {
+		w.writeTee(records)
+	}
+	if w.archiver != nil {
+		w.archiver.add(records)
+	}
+	return w.enqueue(newRecords(records), priority)
+}
+
+// prepare transforms records and applies the oversize policy, validation and tenant limits,
+// passing the records it drops to the reject handler.
+func (w *Writer) prepare(records [][]byte) [][]byte {
+	if len(w.config.transforms) > 0 {
+		records = w.transform(records)
+	}
+	records = w.limitSize(records)
+	records = w.validate(records)
+	if !w.config.allowEmptyRecords {
+		records = slices.DeleteFunc(records, func(record []byte) bool { return len(record) == 0 })
+	}
+	return w.limitTenants(records)
+}
+
+// enqueue sends records immediately or writes them to the buffer of their priority.
+func (w *Writer) enqueue(records []Record, priority func(record []byte) Priority) error {
+	if w.flusher.mutationGuard != nil {
+		guard(records)
+	}
+	if w.immediate() {
+		return w.writeImmediate(records)
+	}
+	var deadline time.Time
+	if w.config.writeDeadline > 0 {
+		deadline = time.Now().Add(w.config.writeDeadline)
+	}
+	for i, record := range records {
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return newWriteTimeoutError(w.config.writeDeadline, records[i:])
+		}
+		data := record.Data
+		buf := w.highBuffer
+		if r := w.routes.lookup(data); r != nil {
+			buf = r.buffer
+		} else if priority(data) != PriorityHigh {
+			if w.config.memoryShedder != nil && w.config.memoryShedder.pressured() {
+				w.config.rejectHandler(ErrMemoryPressure, data)
+				continue
+			}
+			buf = w.kinesisBuffer
+		}
+		w.flusher.health.enqueued(1, len(data))
+		if _, err := buf.Write(record); err != nil {
+			w.flusher.health.enqueued(-1, -len(data))
+			if w.flusher.advisor != nil && errors.Is(err, buffer.ErrWriteTimeout) {
+				w.flusher.advisor.writeTimedOut()
+			}
+			if !deadline.IsZero() && errors.Is(err, buffer.ErrWriteTimeout) {
+				return newWriteTimeoutError(w.config.writeDeadline, records[i:])
+			}
+			if errors.Is(err, ErrWriterClosed) {
+				return err
+			}
+			return fmt.Errorf("failed to write to buffer: %w", err)
+		}
+	}
+	return nil
+}
+
+func (w *Writer) writeTee(records [][]byte) {
+	w.teeMu.Lock()
+	defer w.teeMu.Unlock()
+	for _, record := range records {
+		if _, err := w.config.tee.Write(append(record[:len(record):len(record)], '\n')); err != nil {
+			log.Printf("failed to write to tee: %s", err)
+			return
+		}
+	}
+}
+
+func (w *Writer) immediate() bool {
+	return w.config.immediateFlush || w.config.bufferConfig.recordWindow == 1
+}
+
+// writeImmediate sends records synchronously, bypassing the buffer.
+func (w *Writer) writeImmediate(records []Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	var errs []error
+	flushers, batches := w.routes.group(records, w.flusher)
+	for i, fl := range flushers {
+		if len(batches[i]) == 0 {
+			continue
+		}
+		fl.health.enqueued(len(batches[i]), recordsSize(recordsData(batches[i])))
+		if err := fl.flushRecords(batches[i]); err != nil {
+			errs = append(errs, fmt.Errorf("failed to flush records: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// MemoryUsage returns the approximate number of bytes of record data held by the writer,
+// both waiting in the buffer and being delivered or retried.
+func (w *Writer) MemoryUsage() int64 {
+	return w.flusher.health.pendingBytes.Load()
 }
 
 func (w *Writer) Sync() error {
-	w.kinesisBuffer.Flush()
+	var errs []error
+	for _, buf := range w.buffers() {
+		errs = append(errs, buf.Flush())
+	}
+	return errors.Join(errs...)
+}
+
+// Wait flushes the buffers and blocks until every record written so far, including
+// records written while waiting, has been delivered or passed to the error handler,
+// or until ctx is done. The writer stays open.
+func (w *Writer) Wait(ctx context.Context) error {
+	ticker := time.NewTicker(waitPollInterval)
+	defer ticker.Stop()
+	for w.flusher.health.pending.Load() > 0 {
+		// a flush request misses the records the buffer has not taken in yet, so repeat it.
+		if err := w.Sync(); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
 	return nil
 }
 
-func (w *Writer) Close() error {
-	if err := w.kinesisBuffer.Close(); err != nil {
-		return fmt.Errorf("failed to close buffer: %w", err)
+// FlushSync flushes the buffers and returns once the flushes have finished, so that tests can
+// observe the records sent without sleeping. Delivery errors still go to the error handler.
+func (w *Writer) FlushSync() error {
+	return w.Wait(context.Background())
+}
+
+// Err returns the first *FlushError of a background flush caused by a permanent error code,
+// such as AccessDeniedException or ResourceNotFoundException, or nil. Once it is set,
+// later records are likely to fail the same way, so applications can stop writing
+// instead of losing data to the error handler.
+func (w *Writer) Err() error {
+	for _, fl := range w.flushers() {
+		if err := fl.fatal.Load(); err != nil {
+			return err
+		}
 	}
 	return nil
 }
+
+// flushers returns the flusher of the writer followed by those of its routes.
+func (w *Writer) flushers() []*flusher {
+	flushers := []*flusher{w.flusher}
+	for _, r := range w.routes.sorted() {
+		flushers = append(flushers, r.flusher)
+	}
+	return flushers
+}
+
+// buffers returns the buffers of the writer, high priority first.
+func (w *Writer) buffers() []Buffer {
+	buffers := []Buffer{w.highBuffer, w.kinesisBuffer}
+	for _, r := range w.routes.sorted() {
+		buffers = append(buffers, r.buffer)
+	}
+	return buffers
+}
+
+// Len returns the number of records waiting in the buffers.
+func (w *Writer) Len() int {
+	var n int
+	for _, buf := range w.buffers() {
+		n += buf.Len()
+	}
+	return n
+}
+
+// Shutdown stops accepting records: Write and the other write methods return ErrWriterClosed
+// from then on, while the buffered records keep being flushed and retried in the background.
+// Wait blocks until they are delivered, and Close is still needed to stop the writer.
+// It returns ErrWriterClosed if the writer is already shut down or closed.
+func (w *Writer) Shutdown() error {
+	err := w.writePartial()
+	w.closeMu.Lock()
+	swapped := !w.closed.Load() && w.shutdown.CompareAndSwap(false, true)
+	w.closeMu.Unlock()
+	if !swapped {
+		return ErrWriterClosed
+	}
+	w.stopPeriodic()
+	return errors.Join(err, w.Sync())
+}
+
+// Close flushes the buffered records and stops the writer.
+// It returns ErrWriterClosed if the writer is already closed.
+func (w *Writer) Close() error {
+	var errs []error
+	if err := w.writePartial(); err != nil {
+		errs = append(errs, err)
+	}
+	w.closeMu.Lock()
+	swapped := w.closed.CompareAndSwap(false, true)
+	w.closeMu.Unlock()
+	if !swapped {
+		return ErrWriterClosed
+	}
+	w.stopPeriodic()
+	for _, fl := range w.flushers() {
+		fl.closing.Store(true)
+	}
+	if w.archiver != nil {
+		w.archiver.close()
+	}
+	// high priority records go first.
+	for _, buf := range w.buffers() {
+		if err := buf.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close buffer: %w", err))
+		}
+	}
+	if w.reporter != nil {
+		w.reporter.stop()
+	}
+	if w.config.manager != nil {
+		w.config.manager.remove(w)
+	}
+	return errors.Join(errs...)
+}
+
+// stopped reports whether the writer refuses new records after Shutdown or Close.
+func (w *Writer) stopped() bool {
+	return w.closed.Load() || w.shutdown.Load()
+}
+
+// writePartial writes the data held by WithHoldPartialLine as the last record.
+func (w *Writer) writePartial() error {
+	w.partialMu.Lock()
+	partial := w.partial
+	w.partial = nil
+	w.partialMu.Unlock()
+	if len(partial) == 0 {
+		return nil
+	}
+	return w.write(partial)
+}
+
+// stopPeriodic stops the tasks writing records of their own.
+func (w *Writer) stopPeriodic() {
+	for _, p := range []*periodic{w.heartbeat, w.canary, w.announcer} {
+		if p != nil {
+			p.stop()
+		}
+	}
+}
@@ -2,15 +2,26 @@ package kinesiswriter_test
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"slices"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/kinesis"
 	"github.com/aws/aws-sdk-go-v2/service/kinesis/types"
+	"github.com/aws/smithy-go"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	kinesiswriter "github.com/mackee/go-kinesis-writer"
@@ -18,6 +29,8 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+const testStreamARN = "arn:aws:kinesis:ap-northeast-1:123456789012:stream/test-stream"
+
 type testKinesisClient interface {
 	kinesiswriter.KinesisClient
 	Inputs() []*kinesis.PutRecordsInput
@@ -46,7 +59,7 @@ func TestWriter(t *testing.T) {
 		{
 			name: "success: one record",
 			init: init{
-				streamARN:     "stream-arn",
+				streamARN:     testStreamARN,
 				kinesisClient: &successKinesisClient{},
 			},
 			input: input{
@@ -62,7 +75,7 @@ func TestWriter(t *testing.T) {
 								Data: []byte("record1"),
 							},
 						},
-						StreamARN: aws.String("stream-arn"),
+						StreamARN: aws.String(testStreamARN),
 					},
 				},
 			},
@@ -70,7 +83,7 @@ func TestWriter(t *testing.T) {
 		{
 			name: "success: multi line records",
 			init: init{
-				streamARN:     "stream-arn",
+				streamARN:     testStreamARN,
 				kinesisClient: &successKinesisClient{},
 			},
 			input: input{
@@ -89,7 +102,7 @@ func TestWriter(t *testing.T) {
 								Data: []byte("record2"),
 							},
 						},
-						StreamARN: aws.String("stream-arn"),
+						StreamARN: aws.String(testStreamARN),
 					},
 				},
 			},
@@ -97,7 +110,7 @@ func TestWriter(t *testing.T) {
 		{
 			name: "success: WithSplitFunc",
 			init: init{
-				streamARN:     "stream-arn",
+				streamARN:     testStreamARN,
 				kinesisClient: &successKinesisClient{},
 				opts: []kinesiswriter.WriterConfigOption{
 					kinesiswriter.WithSplitFunc(bufio.ScanWords),
@@ -119,7 +132,7 @@ func TestWriter(t *testing.T) {
 								Data: []byte("world"),
 							},
 						},
-						StreamARN: aws.String("stream-arn"),
+						StreamARN: aws.String(testStreamARN),
 					},
 				},
 			},
@@ -127,7 +140,7 @@ func TestWriter(t *testing.T) {
 		{
 			name: "success: window",
 			init: init{
-				streamARN:     "stream-arn",
+				streamARN:     testStreamARN,
 				kinesisClient: &successKinesisClient{},
 				opts: []kinesiswriter.WriterConfigOption{
 					kinesiswriter.WithBufferRecordWindow(3),
@@ -150,13 +163,46 @@ func TestWriter(t *testing.T) {
 							{Data: []byte("record2")},
 							{Data: []byte("record3")},
 						},
-						StreamARN: aws.String("stream-arn"),
+						StreamARN: aws.String(testStreamARN),
 					},
 					{
 						Records: []types.PutRecordsRequestEntry{
 							{Data: []byte("record4")},
 						},
-						StreamARN: aws.String("stream-arn"),
+						StreamARN: aws.String(testStreamARN),
+					},
+				},
+			},
+		},
+		{
+			name: "success: WithImmediateFlush",
+			init: init{
+				streamARN:     testStreamARN,
+				kinesisClient: &successKinesisClient{},
+				opts: []kinesiswriter.WriterConfigOption{
+					kinesiswriter.WithImmediateFlush(),
+				},
+			},
+			input: input{
+				records: [][]byte{
+					[]byte("record1\nrecord2"),
+					[]byte("record3"),
+				},
+			},
+			expect: expect{
+				inputs: []*kinesis.PutRecordsInput{
+					{
+						Records: []types.PutRecordsRequestEntry{
+							{Data: []byte("record1")},
+							{Data: []byte("record2")},
+						},
+						StreamARN: aws.String(testStreamARN),
+					},
+					{
+						Records: []types.PutRecordsRequestEntry{
+							{Data: []byte("record3")},
+						},
+						StreamARN: aws.String(testStreamARN),
 					},
 				},
 			},
@@ -164,7 +210,7 @@ func TestWriter(t *testing.T) {
 		{
 			name: "success: partial failed putRecords",
 			init: init{
-				streamARN:     "stream-arn",
+				streamARN:     testStreamARN,
 				kinesisClient: &partialFailedKinesisClient{},
 			},
 			input: input{
@@ -184,20 +230,76 @@ func TestWriter(t *testing.T) {
 							{Data: []byte("record3")},
 							{Data: []byte("record4")},
 						},
-						StreamARN: aws.String("stream-arn"),
+						StreamARN: aws.String(testStreamARN),
+					},
+					{
+						Records: []types.PutRecordsRequestEntry{
+							{Data: []byte("record2")},
+							{Data: []byte("record4")},
+						},
+						StreamARN: aws.String(testStreamARN),
+					},
+					{
+						Records: []types.PutRecordsRequestEntry{
+							{Data: []byte("record4")},
+						},
+						StreamARN: aws.String(testStreamARN),
+					},
+				},
+			},
+		},
+		{
+			name: "success: WithRetryBackoffByErrorCode",
+			init: init{
+				streamARN:     testStreamARN,
+				kinesisClient: &partialFailedKinesisClient{},
+				opts: []kinesiswriter.WriterConfigOption{
+					kinesiswriter.WithRetryBackoffByErrorCode(map[string]kinesiswriter.RetryBackoff{
+						"error": {MinDelay: time.Millisecond, MaxDelay: time.Millisecond},
+					}),
+				},
+			},
+			input: input{
+				records: [][]byte{
+					[]byte("record1\nrecord2\nrecord3\nrecord4\nrecord5\nrecord6\nrecord7\nrecord8"),
+				},
+			},
+			expect: expect{
+				inputs: []*kinesis.PutRecordsInput{
+					{
+						Records: []types.PutRecordsRequestEntry{
+							{Data: []byte("record1")},
+							{Data: []byte("record2")},
+							{Data: []byte("record3")},
+							{Data: []byte("record4")},
+							{Data: []byte("record5")},
+							{Data: []byte("record6")},
+							{Data: []byte("record7")},
+							{Data: []byte("record8")},
+						},
+						StreamARN: aws.String(testStreamARN),
 					},
 					{
 						Records: []types.PutRecordsRequestEntry{
 							{Data: []byte("record2")},
 							{Data: []byte("record4")},
+							{Data: []byte("record6")},
+							{Data: []byte("record8")},
 						},
-						StreamARN: aws.String("stream-arn"),
+						StreamARN: aws.String(testStreamARN),
 					},
 					{
 						Records: []types.PutRecordsRequestEntry{
 							{Data: []byte("record4")},
+							{Data: []byte("record8")},
+						},
+						StreamARN: aws.String(testStreamARN),
+					},
+					{
+						Records: []types.PutRecordsRequestEntry{
+							{Data: []byte("record8")},
 						},
-						StreamARN: aws.String("stream-arn"),
+						StreamARN: aws.String(testStreamARN),
 					},
 				},
 			},
@@ -231,6 +333,755 @@ func TestWriter(t *testing.T) {
 	}
 }
 
+func TestWriterImmediateFlushError(t *testing.T) {
+	ctx := context.Background()
+	client := &errorKinesisClient{err: errors.New("boom")}
+	writer, err := kinesiswriter.New(ctx, testStreamARN,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithImmediateFlush(),
+	)
+	require.NoError(t, err)
+	n, err := writer.Write([]byte("record1\n"))
+	assert.ErrorContains(t, err, "boom")
+	assert.Equal(t, 0, n)
+	require.NoError(t, writer.Close())
+}
+
+func TestWriterPutRecordsResultHook(t *testing.T) {
+	ctx := context.Background()
+	client := &partialFailedKinesisClient{}
+	var failedCounts []int32
+	writer, err := kinesiswriter.New(ctx, testStreamARN,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithImmediateFlush(),
+		kinesiswriter.WithPutRecordsResultHook(func(in *kinesis.PutRecordsInput, out *kinesis.PutRecordsOutput, err error) {
+			require.NoError(t, err)
+			assert.Len(t, out.Records, len(in.Records))
+			failedCounts = append(failedCounts, aws.ToInt32(out.FailedRecordCount))
+		}),
+	)
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("record1\nrecord2\nrecord3"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	assert.Equal(t, []int32{1, 0}, failedCounts)
+}
+
+func TestWriterPutRecordsMiddleware(t *testing.T) {
+	ctx := context.Background()
+	client := &successKinesisClient{}
+	var calls []string
+	named := func(name string) kinesiswriter.PutRecordsMiddleware {
+		return func(next kinesiswriter.PutRecordsFunc) kinesiswriter.PutRecordsFunc {
+			return func(ctx context.Context, params *kinesis.PutRecordsInput, optFns ...func(*kinesis.Options)) (*kinesis.PutRecordsOutput, error) {
+				calls = append(calls, name)
+				for i := range params.Records {
+					params.Records[i].Data = append([]byte(name+":"), params.Records[i].Data...)
+				}
+				return next(ctx, params, optFns...)
+			}
+		}
+	}
+	writer, err := kinesiswriter.New(ctx, testStreamARN,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithImmediateFlush(),
+		kinesiswriter.WithPutRecordsMiddleware(named("outer"), named("inner")),
+	)
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("record1"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	assert.Equal(t, []string{"outer", "inner"}, calls)
+	require.Len(t, client.Inputs(), 1)
+	assert.Equal(t, []byte("inner:outer:record1"), client.Inputs()[0].Records[0].Data)
+}
+
+func TestWriterHealthy(t *testing.T) {
+	ctx := context.Background()
+	client := &errorKinesisClient{}
+	writer, err := kinesiswriter.New(ctx, testStreamARN,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithImmediateFlush(),
+	)
+	require.NoError(t, err)
+	handler := writer.HealthHandler()
+
+	ok, err := writer.Healthy()
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	client.err = errors.New("boom")
+	_, err = writer.Write([]byte("record1"))
+	require.Error(t, err)
+	ok, err = writer.Healthy()
+	assert.False(t, ok)
+	assert.ErrorContains(t, err, "boom")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	client.err = nil
+	_, err = writer.Write([]byte("record2"))
+	require.NoError(t, err)
+	ok, _ = writer.Healthy()
+	assert.True(t, ok)
+	assert.ErrorContains(t, writer.LastError(), "boom")
+	assert.False(t, writer.LastErrorAt().IsZero())
+	assert.Equal(t, 0.5, writer.FailureRate())
+	require.NoError(t, writer.Close())
+}
+
+func TestWriterFailureWindow(t *testing.T) {
+	client := &errorKinesisClient{err: errors.New("boom")}
+	writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithImmediateFlush(),
+		kinesiswriter.WithFailureWindow(60*time.Millisecond),
+	)
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("record1"))
+	require.Error(t, err)
+	assert.Equal(t, 1.0, writer.FailureRate())
+	assert.Eventually(t, func() bool { return writer.FailureRate() == 0 }, time.Second, 10*time.Millisecond)
+	require.NoError(t, writer.Close())
+}
+
+func TestWriterSlowFlushThreshold(t *testing.T) {
+	ctx := context.Background()
+	var slow []int
+	writer, err := kinesiswriter.New(ctx, testStreamARN,
+		kinesiswriter.WithKinesisClient(&successKinesisClient{}),
+		kinesiswriter.WithImmediateFlush(),
+		kinesiswriter.WithPutRecordsMiddleware(func(next kinesiswriter.PutRecordsFunc) kinesiswriter.PutRecordsFunc {
+			return func(ctx context.Context, params *kinesis.PutRecordsInput, optFns ...func(*kinesis.Options)) (*kinesis.PutRecordsOutput, error) {
+				if len(params.Records) > 1 {
+					time.Sleep(20 * time.Millisecond)
+				}
+				return next(ctx, params, optFns...)
+			}
+		}),
+		kinesiswriter.WithSlowFlushThreshold(10*time.Millisecond, func(elapsed time.Duration, records int) {
+			assert.Greater(t, elapsed, 10*time.Millisecond)
+			slow = append(slow, records)
+		}),
+	)
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("record1"))
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("record2\nrecord3"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	assert.Equal(t, []int{2}, slow)
+}
+
+func TestWriterPutRecordsTimeout(t *testing.T) {
+	ctx := context.Background()
+	client := &successKinesisClient{}
+	var calls int
+	writer, err := kinesiswriter.New(ctx, testStreamARN,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithImmediateFlush(),
+		kinesiswriter.WithPutRecordsTimeout(10*time.Millisecond),
+		kinesiswriter.WithFlushDeadline(time.Second),
+		kinesiswriter.WithPutRecordsMiddleware(func(next kinesiswriter.PutRecordsFunc) kinesiswriter.PutRecordsFunc {
+			return func(ctx context.Context, params *kinesis.PutRecordsInput, optFns ...func(*kinesis.Options)) (*kinesis.PutRecordsOutput, error) {
+				calls++
+				if calls == 1 {
+					<-ctx.Done()
+					return nil, ctx.Err()
+				}
+				return next(ctx, params, optFns...)
+			}
+		}),
+	)
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("record1"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	assert.Equal(t, 2, calls)
+	assert.Len(t, client.Inputs(), 1)
+}
+
+func TestWriterMaxInFlightBatches(t *testing.T) {
+	ctx := context.Background()
+	var inFlight, maxInFlight atomic.Int32
+	writer, err := kinesiswriter.New(ctx, testStreamARN,
+		kinesiswriter.WithKinesisClient(&successKinesisClient{}),
+		kinesiswriter.WithImmediateFlush(),
+		kinesiswriter.WithMaxInFlightBatches(1),
+		kinesiswriter.WithPutRecordsMiddleware(func(next kinesiswriter.PutRecordsFunc) kinesiswriter.PutRecordsFunc {
+			return func(ctx context.Context, params *kinesis.PutRecordsInput, optFns ...func(*kinesis.Options)) (*kinesis.PutRecordsOutput, error) {
+				n := inFlight.Add(1)
+				defer inFlight.Add(-1)
+				for {
+					m := maxInFlight.Load()
+					if n <= m || maxInFlight.CompareAndSwap(m, n) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+				return &kinesis.PutRecordsOutput{
+					Records: make([]types.PutRecordsResultEntry, len(params.Records)),
+				}, nil
+			}
+		}),
+	)
+	require.NoError(t, err)
+	var wg sync.WaitGroup
+	for i := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := writer.Write([]byte("record" + strconv.Itoa(i)))
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	require.NoError(t, writer.Close())
+	assert.Equal(t, int32(1), maxInFlight.Load())
+}
+
+func TestWriterMemoryUsage(t *testing.T) {
+	ctx := context.Background()
+	writer, err := kinesiswriter.New(ctx, testStreamARN,
+		kinesiswriter.WithKinesisClient(&successKinesisClient{}),
+		kinesiswriter.WithBufferFlushInterval(time.Hour),
+	)
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("record1\nrecord2"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(14), writer.MemoryUsage())
+	require.NoError(t, writer.Close())
+	assert.Equal(t, int64(0), writer.MemoryUsage())
+}
+
+func TestWriterStats(t *testing.T) {
+	ctx := context.Background()
+	writer, err := kinesiswriter.New(ctx, testStreamARN,
+		kinesiswriter.WithKinesisClient(&partialFailedKinesisClient{}),
+		kinesiswriter.WithImmediateFlush(),
+	)
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("record1\nrecord2\nrecord3"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	stats := writer.Stats()
+	assert.Equal(t, int64(3), stats.RecordsSent)
+	assert.Equal(t, int64(1), stats.RecordsFailed)
+	assert.Equal(t, map[string]int64{"error": 1}, stats.FailuresByErrorCode)
+	assert.Len(t, stats.Shards, 3)
+	for _, ss := range stats.Shards {
+		assert.Equal(t, kinesiswriter.ShardStats{RecordsSent: 1, BytesSent: 7}, ss)
+	}
+	assert.Equal(t, int64(3), stats.PayloadUnits)
+	assert.Positive(t, stats.Elapsed)
+}
+
+func TestStatsEstimatedMonthlyCost(t *testing.T) {
+	stats := kinesiswriter.Stats{PayloadUnits: 1_000_000, Elapsed: 24 * time.Hour}
+	assert.InDelta(t, 30*0.014, stats.EstimatedMonthlyCost(kinesiswriter.DefaultPayloadUnitPrice), 1e-9)
+	assert.Zero(t, kinesiswriter.Stats{PayloadUnits: 1}.EstimatedMonthlyCost(kinesiswriter.DefaultPayloadUnitPrice))
+}
+
+func TestWriterStatsPayloadUnits(t *testing.T) {
+	writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+		kinesiswriter.WithKinesisClient(&successKinesisClient{}),
+		kinesiswriter.WithImmediateFlush(),
+	)
+	require.NoError(t, err)
+	_, err = writer.Write(append(bytes.Repeat([]byte("a"), 25*1024+1), '\n'))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	assert.Equal(t, int64(2), writer.Stats().PayloadUnits)
+}
+
+func TestWriterStatsReporter(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		reports []kinesiswriter.Stats
+	)
+	writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+		kinesiswriter.WithKinesisClient(&successKinesisClient{}),
+		kinesiswriter.WithImmediateFlush(),
+		kinesiswriter.WithStatsReporter(10*time.Millisecond, func(stats kinesiswriter.Stats) {
+			mu.Lock()
+			defer mu.Unlock()
+			reports = append(reports, stats)
+		}),
+	)
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("record1\nrecord2\n"))
+	require.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, writer.Close())
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Greater(t, len(reports), 1)
+	assert.Equal(t, int64(2), reports[len(reports)-1].RecordsSent)
+}
+
+func TestWriterHeartbeat(t *testing.T) {
+	client := &successKinesisClient{}
+	writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithImmediateFlush(),
+		kinesiswriter.WithTransform(kinesiswriter.LogfmtToJSON),
+		kinesiswriter.WithHeartbeat(10*time.Millisecond, func() []byte {
+			return []byte(`{"heartbeat":true}`)
+		}),
+	)
+	require.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, writer.Close())
+
+	var records []string
+	for _, input := range client.Inputs() {
+		for _, entry := range input.Records {
+			records = append(records, string(entry.Data))
+		}
+	}
+	require.GreaterOrEqual(t, len(records), 2)
+	for _, record := range records {
+		assert.Equal(t, `{"heartbeat":true}`, record, "heartbeats skip transforms")
+	}
+}
+
+func TestWriterCanary(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		results []kinesiswriter.CanaryResult
+	)
+	writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+		kinesiswriter.WithKinesisClient(&successKinesisClient{}),
+		kinesiswriter.WithBufferFlushInterval(20*time.Millisecond),
+		kinesiswriter.WithCanary(10*time.Millisecond, func(result kinesiswriter.CanaryResult) {
+			mu.Lock()
+			defer mu.Unlock()
+			results = append(results, result)
+		}),
+	)
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("record1\n"))
+	require.NoError(t, err)
+	time.Sleep(60 * time.Millisecond)
+	require.NoError(t, writer.Close())
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.NotEmpty(t, results)
+	assert.Equal(t, "1", results[0].ID)
+	assert.Positive(t, results[0].Latency)
+	assert.Equal(t, results[0].Latency, results[0].AcceptedAt.Sub(results[0].SentAt))
+	assert.NotEmpty(t, results[0].SequenceNumber)
+}
+
+func TestWriterPeriodicInterval(t *testing.T) {
+	tests := []struct {
+		name string
+		opt  kinesiswriter.WriterConfigOption
+	}{
+		{name: "heartbeat", opt: kinesiswriter.WithHeartbeat(0, func() []byte { return nil })},
+		{name: "stats reporter", opt: kinesiswriter.WithStatsReporter(-time.Second, func(kinesiswriter.Stats) {})},
+		{name: "canary", opt: kinesiswriter.WithCanary(0, func(kinesiswriter.CanaryResult) {})},
+		{name: "encoding announcement", opt: kinesiswriter.WithEncodingAnnouncement(0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := kinesiswriter.New(context.Background(), testStreamARN,
+				kinesiswriter.WithKinesisClient(&successKinesisClient{}),
+				tt.opt,
+			)
+			assert.ErrorContains(t, err, "must be positive")
+		})
+	}
+}
+
+func TestWriterHotShardWarning(t *testing.T) {
+	ctx := context.Background()
+	hot := map[string]float64{}
+	writer, err := kinesiswriter.New(ctx, testStreamARN,
+		kinesiswriter.WithKinesisClient(&successKinesisClient{}),
+		kinesiswriter.WithImmediateFlush(),
+		kinesiswriter.WithHotShardWarning(0.5, 4, func(shardID string, share float64) {
+			hot[shardID] = share
+		}),
+		kinesiswriter.WithPutRecordsMiddleware(func(next kinesiswriter.PutRecordsFunc) kinesiswriter.PutRecordsFunc {
+			return func(ctx context.Context, params *kinesis.PutRecordsInput, optFns ...func(*kinesis.Options)) (*kinesis.PutRecordsOutput, error) {
+				entries := make([]types.PutRecordsResultEntry, len(params.Records))
+				for i, r := range params.Records {
+					entries[i] = types.PutRecordsResultEntry{
+						SequenceNumber: aws.String("1"),
+						ShardId:        aws.String("shardId-" + string(r.Data)),
+					}
+				}
+				return &kinesis.PutRecordsOutput{Records: entries}, nil
+			}
+		}),
+	)
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("1\n2\n3\n4"))
+	require.NoError(t, err)
+	assert.Empty(t, hot)
+	_, err = writer.Write([]byte("1\n1\n1\n2"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"shardId-1": 0.75}, hot)
+	require.NoError(t, writer.Close())
+}
+
+func TestWriterHotShardWarningSingleKey(t *testing.T) {
+	ctx := context.Background()
+	client := &shardListerKinesisClient{}
+	for i := range 4 {
+		client.shards = append(client.shards, types.Shard{ShardId: aws.String(fmt.Sprintf("shardId-%d", i))})
+	}
+	hot := map[string]float64{}
+	writer, err := kinesiswriter.New(ctx, testStreamARN,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithImmediateFlush(),
+		kinesiswriter.WithPartitioner(kinesiswriter.StaticPartitioner("static")),
+		kinesiswriter.WithHotShardWarning(0.5, 4, func(shardID string, share float64) {
+			hot[shardID] = share
+		}),
+		kinesiswriter.WithPutRecordsMiddleware(func(next kinesiswriter.PutRecordsFunc) kinesiswriter.PutRecordsFunc {
+			return func(ctx context.Context, params *kinesis.PutRecordsInput, optFns ...func(*kinesis.Options)) (*kinesis.PutRecordsOutput, error) {
+				entries := make([]types.PutRecordsResultEntry, len(params.Records))
+				for i := range params.Records {
+					entries[i] = types.PutRecordsResultEntry{SequenceNumber: aws.String("1"), ShardId: aws.String("shardId-0")}
+				}
+				return &kinesis.PutRecordsOutput{Records: entries}, nil
+			}
+		}),
+	)
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("1\n2\n3\n4"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	assert.Equal(t, map[string]float64{"shardId-0": 1}, hot)
+}
+
+func TestWriterRoundRobinHashKeys(t *testing.T) {
+	ctx := context.Background()
+	client := &shardListerKinesisClient{
+		shards: []types.Shard{
+			{ShardId: aws.String("shardId-0"), HashKeyRange: &types.HashKeyRange{StartingHashKey: aws.String("0"), EndingHashKey: aws.String("99")}},
+			{ShardId: aws.String("shardId-1"), HashKeyRange: &types.HashKeyRange{StartingHashKey: aws.String("100"), EndingHashKey: aws.String("199")}},
+		},
+	}
+	writer, err := kinesiswriter.New(ctx, testStreamARN,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithImmediateFlush(),
+		kinesiswriter.WithRoundRobinHashKeys(),
+	)
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("record1\nrecord2\nrecord3\nrecord4"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	require.Len(t, client.Inputs(), 1)
+	counts := map[string]int{}
+	for _, entry := range client.Inputs()[0].Records {
+		counts[aws.ToString(entry.ExplicitHashKey)]++
+	}
+	assert.Equal(t, map[string]int{"0": 2, "100": 2}, counts)
+	assert.Equal(t, 1, client.listCalls)
+
+	_, err = kinesiswriter.New(ctx, testStreamARN,
+		kinesiswriter.WithKinesisClient(&successKinesisClient{}),
+		kinesiswriter.WithRoundRobinHashKeys(),
+	)
+	assert.Error(t, err)
+}
+
+func TestWriterRoundRobinHashKeysResharding(t *testing.T) {
+	shard := func(id, start, end string) types.Shard {
+		return types.Shard{ShardId: aws.String(id), HashKeyRange: &types.HashKeyRange{StartingHashKey: aws.String(start), EndingHashKey: aws.String(end)}}
+	}
+	client := &reshardingKinesisClient{acceptedBy: "shardId-0"}
+	client.shards = []types.Shard{shard("shardId-0", "0", "99"), shard("shardId-1", "100", "199")}
+	writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithImmediateFlush(),
+		kinesiswriter.WithNoRetry(),
+		kinesiswriter.WithRoundRobinHashKeys(),
+	)
+	require.NoError(t, err)
+	hashKeys := func() []string {
+		inputs := client.Inputs()
+		var keys []string
+		for _, entry := range inputs[len(inputs)-1].Records {
+			keys = append(keys, aws.ToString(entry.ExplicitHashKey))
+		}
+		slices.Sort(keys)
+		return keys
+	}
+
+	_, err = writer.Write([]byte("record1\nrecord2"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"0", "100"}, hashKeys())
+
+	// a shard missing from the shard map accepts records after a split.
+	client.setShards([]types.Shard{shard("shardId-1", "100", "199"), shard("shardId-2", "0", "49"), shard("shardId-3", "50", "99")}, nil)
+	client.acceptedBy = "shardId-2"
+	_, err = writer.Write([]byte("record1\nrecord2"))
+	require.NoError(t, err)
+	// the shard map is refreshed in the background, pausing explicit hash keys meanwhile.
+	assert.Eventually(t, func() bool {
+		_, err := writer.Write([]byte("record1\nrecord2\nrecord3"))
+		require.NoError(t, err)
+		return slices.Equal([]string{"0", "100", "50"}, hashKeys())
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 2, client.ListCalls())
+
+	// explicit hash keys are paused while the shards cannot be listed.
+	client.putErr = &smithy.GenericAPIError{Code: "ResourceInUseException"}
+	_, err = writer.Write([]byte("record1"))
+	require.Error(t, err)
+	client.putErr = nil
+	client.setShards(client.shards, errors.New("resharding"))
+	_, err = writer.Write([]byte("record1\nrecord2"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"", ""}, hashKeys())
+	assert.Eventually(t, func() bool { return client.ListCalls() == 3 }, time.Second, 10*time.Millisecond)
+	require.NoError(t, writer.Close())
+}
+
+func TestWriterRoundRobinHashKeysSlowRefresh(t *testing.T) {
+	client := &reshardingKinesisClient{acceptedBy: "shardId-0"}
+	client.shards = []types.Shard{
+		{ShardId: aws.String("shardId-0"), HashKeyRange: &types.HashKeyRange{StartingHashKey: aws.String("0"), EndingHashKey: aws.String("99")}},
+	}
+	writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithImmediateFlush(),
+		kinesiswriter.WithNoRetry(),
+		kinesiswriter.WithRoundRobinHashKeys(),
+	)
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("record1"))
+	require.NoError(t, err)
+
+	// records keep flowing without explicit hash keys while the stale shard map is refreshed.
+	client.release = make(chan struct{})
+	client.putErr = &smithy.GenericAPIError{Code: "ResourceInUseException"}
+	_, err = writer.Write([]byte("record1"))
+	require.Error(t, err)
+	client.putErr = nil
+	for range 3 {
+		_, err = writer.Write([]byte("record1"))
+		require.NoError(t, err)
+	}
+	inputs := client.Inputs()
+	assert.Nil(t, inputs[len(inputs)-1].Records[0].ExplicitHashKey)
+	close(client.release)
+	require.NoError(t, writer.Close())
+}
+
+func TestWriterDeterministicPartitionKeys(t *testing.T) {
+	ctx := context.Background()
+	send := func(opt kinesiswriter.WriterConfigOption) []*kinesis.PutRecordsInput {
+		client := &successKinesisClient{}
+		writer, err := kinesiswriter.New(ctx, testStreamARN,
+			kinesiswriter.WithKinesisClient(client),
+			kinesiswriter.WithImmediateFlush(),
+			opt,
+		)
+		require.NoError(t, err)
+		_, err = writer.Write([]byte("record1\nrecord2"))
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+		return client.Inputs()
+	}
+	opts := cmpopts.IgnoreUnexported(kinesis.PutRecordsInput{}, types.PutRecordsRequestEntry{})
+
+	expect := []*kinesis.PutRecordsInput{
+		{
+			Records: []types.PutRecordsRequestEntry{
+				{Data: []byte("record1"), PartitionKey: aws.String("0")},
+				{Data: []byte("record2"), PartitionKey: aws.String("1")},
+			},
+			StreamARN: aws.String(testStreamARN),
+		},
+	}
+	if diff := cmp.Diff(expect, send(kinesiswriter.WithSequentialPartitionKeys()), opts); diff != "" {
+		t.Errorf("unexpected inputs (-want, +got):\n%s", diff)
+	}
+
+	seeded := send(kinesiswriter.WithPartitionKeySeed(42))
+	if diff := cmp.Diff(seeded, send(kinesiswriter.WithPartitionKeySeed(42)), opts); diff != "" {
+		t.Errorf("seeded partition keys differ (-first, +second):\n%s", diff)
+	}
+}
+
+func TestPartitioners(t *testing.T) {
+	prefix := func(record []byte) string {
+		key, _, _ := strings.Cut(string(record), ":")
+		return key
+	}
+	static := kinesiswriter.StaticPartitioner("static")
+	tests := []struct {
+		name     string
+		p        kinesiswriter.Partitioner
+		keys     []string
+		hashKeys []string
+	}{
+		{name: "static", p: static, keys: []string{"static", "static", "static", "static"}},
+		{
+			name: "content",
+			p:    kinesiswriter.ContentPartitioner(prefix, static),
+			keys: []string{"a", "b", "a", "static"},
+		},
+		{
+			name: "sticky",
+			p: kinesiswriter.StickyPartitioner(2, kinesiswriter.PartitionerFunc(func(record []byte) (string, *string) {
+				return string(record), nil
+			})),
+			keys: []string{"a:1", "a:1", "a:2", "a:2"},
+		},
+		{
+			name:     "consistent hash",
+			p:        kinesiswriter.ConsistentHashPartitioner(prefix, 2, static),
+			keys:     []string{"a", "b", "a", "static"},
+			hashKeys: []string{"85070591730234615865843651857942052864", "255211775190703847597530955573826158592", "85070591730234615865843651857942052864", ""},
+		},
+	}
+	records := []string{"a:1", "b:1", "a:2", ":3"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var keys, hashKeys []string
+			for _, record := range records {
+				key, hashKey := tt.p.Key([]byte(record))
+				keys = append(keys, key)
+				hashKeys = append(hashKeys, aws.ToString(hashKey))
+			}
+			assert.Equal(t, tt.keys, keys)
+			if tt.hashKeys == nil {
+				tt.hashKeys = make([]string, len(records))
+			}
+			assert.Equal(t, tt.hashKeys, hashKeys)
+		})
+	}
+
+	// growing the buckets only moves keys to the new bucket.
+	two := kinesiswriter.ConsistentHashPartitioner(prefix, 2, static)
+	three := kinesiswriter.ConsistentHashPartitioner(prefix, 3, static)
+	twoBuckets := []string{"85070591730234615865843651857942052864", "255211775190703847597530955573826158592"}
+	threeBuckets := []string{"56713727820156410577229101238628035242", "170141183460469231731687303715884105727", "283568639100782052886145506193140176212"}
+	moved := 0
+	for i := range 100 {
+		record := []byte(strconv.Itoa(i) + ":")
+		_, before := two.Key(record)
+		_, after := three.Key(record)
+		if b, a := slices.Index(twoBuckets, *before), slices.Index(threeBuckets, *after); a != b {
+			assert.Equal(t, 2, a)
+			moved++
+		}
+	}
+	assert.Greater(t, moved, 0)
+}
+
+func TestWriterPartitioner(t *testing.T) {
+	client := &successKinesisClient{}
+	writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithImmediateFlush(),
+		kinesiswriter.WithPartitioner(kinesiswriter.StaticPartitioner("static")),
+	)
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("record1\nrecord2"))
+	require.NoError(t, err)
+	require.NoError(t, writer.WriteEntries([]types.PutRecordsRequestEntry{{Data: []byte("record3"), PartitionKey: aws.String("own")}}))
+	require.NoError(t, writer.Close())
+
+	var keys []string
+	for _, input := range client.Inputs() {
+		for _, entry := range input.Records {
+			keys = append(keys, aws.ToString(entry.PartitionKey))
+		}
+	}
+	assert.Equal(t, []string{"static", "static", "own"}, keys)
+}
+
+func TestWriterTee(t *testing.T) {
+	ctx := context.Background()
+	var tee bytes.Buffer
+	client := &successKinesisClient{}
+	writer, err := kinesiswriter.New(ctx, testStreamARN,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithImmediateFlush(),
+		kinesiswriter.WithTee(&tee),
+	)
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("record1\nrecord2"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	assert.Equal(t, "record1\nrecord2\n", tee.String())
+	require.Len(t, client.Inputs(), 1)
+	assert.Equal(t, []byte("record1"), client.Inputs()[0].Records[0].Data)
+}
+
+func TestWriterRequestLogger(t *testing.T) {
+	ctx := context.Background()
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	writer, err := kinesiswriter.New(ctx, testStreamARN,
+		kinesiswriter.WithKinesisClient(&partialFailedKinesisClient{}),
+		kinesiswriter.WithImmediateFlush(),
+		kinesiswriter.WithRequestLogger(logger),
+	)
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("record1\nrecord2"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], "msg=\"put records\" records=2")
+	assert.Contains(t, lines[0], "failed_records=1")
+	assert.Contains(t, lines[1], "records=1")
+	assert.Contains(t, lines[1], "failed_records=0")
+}
+
+func TestWriterPreserveOrder(t *testing.T) {
+	ctx := context.Background()
+	client := &partialFailedKinesisClient{}
+	writer, err := kinesiswriter.New(ctx, testStreamARN,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithBufferRecordWindow(2),
+		kinesiswriter.WithBufferFlushTimeout(5*time.Millisecond),
+		kinesiswriter.WithFlushDeadline(time.Second),
+		kinesiswriter.WithPreserveOrder(),
+		kinesiswriter.WithPutRecordsMiddleware(func(next kinesiswriter.PutRecordsFunc) kinesiswriter.PutRecordsFunc {
+			return func(ctx context.Context, params *kinesis.PutRecordsInput, optFns ...func(*kinesis.Options)) (*kinesis.PutRecordsOutput, error) {
+				time.Sleep(20 * time.Millisecond)
+				return next(ctx, params, optFns...)
+			}
+		}),
+	)
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("record1\nrecord2\nrecord3\nrecord4"))
+	require.NoError(t, err)
+	time.Sleep(200 * time.Millisecond)
+	require.NoError(t, writer.Close())
+
+	var sent [][]string
+	for _, input := range client.Inputs() {
+		var records []string
+		for _, entry := range input.Records {
+			records = append(records, string(entry.Data))
+		}
+		sent = append(sent, records)
+	}
+	assert.Equal(t, [][]string{
+		{"record1", "record2"},
+		{"record2"},
+		{"record3", "record4"},
+		{"record4"},
+	}, sent)
+}
+
 type successKinesisClient struct {
 	inputs []*kinesis.PutRecordsInput
 }
@@ -285,3 +1136,634 @@ func (c *partialFailedKinesisClient) PutRecords(ctx context.Context, params *kin
 func (c *partialFailedKinesisClient) Inputs() []*kinesis.PutRecordsInput {
 	return c.inputs
 }
+
+type errorKinesisClient struct {
+	inputs []*kinesis.PutRecordsInput
+	err    error
+}
+
+func (c *errorKinesisClient) PutRecords(ctx context.Context, params *kinesis.PutRecordsInput, optFns ...func(*kinesis.Options)) (*kinesis.PutRecordsOutput, error) {
+	c.inputs = append(c.inputs, params)
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &kinesis.PutRecordsOutput{
+		Records: make([]types.PutRecordsResultEntry, len(params.Records)),
+	}, nil
+}
+
+func (c *errorKinesisClient) Inputs() []*kinesis.PutRecordsInput {
+	return c.inputs
+}
+
+type shardListerKinesisClient struct {
+	successKinesisClient
+	shards    []types.Shard
+	listCalls int
+}
+
+func (c *shardListerKinesisClient) ListShards(ctx context.Context, params *kinesis.ListShardsInput, optFns ...func(*kinesis.Options)) (*kinesis.ListShardsOutput, error) {
+	c.listCalls++
+	return &kinesis.ListShardsOutput{Shards: c.shards}, nil
+}
+
+type reshardingKinesisClient struct {
+	shardListerKinesisClient
+	acceptedBy string
+	putErr     error
+	// mu guards the listing, which runs in the background.
+	mu      sync.Mutex
+	listErr error
+	// release holds ListShards until it is closed, if set.
+	release chan struct{}
+}
+
+func (c *reshardingKinesisClient) setShards(shards []types.Shard, listErr error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.shards, c.listErr = shards, listErr
+}
+
+func (c *reshardingKinesisClient) ListCalls() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.listCalls
+}
+
+func (c *reshardingKinesisClient) PutRecords(ctx context.Context, params *kinesis.PutRecordsInput, optFns ...func(*kinesis.Options)) (*kinesis.PutRecordsOutput, error) {
+	c.inputs = append(c.inputs, params)
+	if c.putErr != nil {
+		return nil, c.putErr
+	}
+	entries := make([]types.PutRecordsResultEntry, len(params.Records))
+	for i := range params.Records {
+		entries[i] = types.PutRecordsResultEntry{SequenceNumber: aws.String("1"), ShardId: aws.String(c.acceptedBy)}
+	}
+	return &kinesis.PutRecordsOutput{Records: entries}, nil
+}
+
+func (c *reshardingKinesisClient) ListShards(ctx context.Context, params *kinesis.ListShardsInput, optFns ...func(*kinesis.Options)) (*kinesis.ListShardsOutput, error) {
+	if c.release != nil {
+		<-c.release
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.listErr != nil {
+		c.listCalls++
+		return nil, c.listErr
+	}
+	return c.shardListerKinesisClient.ListShards(ctx, params, optFns...)
+}
+
+func TestWriterPermanentErrorCodes(t *testing.T) {
+	tests := []struct {
+		name       string
+		code       string
+		opts       []kinesiswriter.WriterConfigOption
+		wantInputs int
+	}{
+		{name: "permanent", code: "AccessDeniedException", wantInputs: 1},
+		{name: "retryable", code: "InternalFailure", wantInputs: 4},
+		{
+			name:       "configured",
+			code:       "InternalFailure",
+			opts:       []kinesiswriter.WriterConfigOption{kinesiswriter.WithPermanentErrorCodes("InternalFailure")},
+			wantInputs: 1,
+		},
+		{
+			name:       "left to the SDK",
+			code:       "InternalFailure",
+			opts:       []kinesiswriter.WriterConfigOption{kinesiswriter.WithAdaptiveRetry()},
+			wantInputs: 1,
+		},
+		{
+			name:       "no retry",
+			code:       "InternalFailure",
+			opts:       []kinesiswriter.WriterConfigOption{kinesiswriter.WithNoRetry()},
+			wantInputs: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &errorKinesisClient{err: &smithy.GenericAPIError{Code: tt.code}}
+			opts := append([]kinesiswriter.WriterConfigOption{
+				kinesiswriter.WithKinesisClient(client),
+				kinesiswriter.WithImmediateFlush(),
+				kinesiswriter.WithRetryBackoffByErrorCode(map[string]kinesiswriter.RetryBackoff{
+					tt.code: {MinDelay: time.Millisecond, MaxDelay: time.Millisecond},
+				}),
+			}, tt.opts...)
+			writer, err := kinesiswriter.New(context.Background(), testStreamARN, opts...)
+			require.NoError(t, err)
+			_, err = writer.Write([]byte("record1\n"))
+			assert.ErrorContains(t, err, tt.code)
+			require.NoError(t, writer.Close())
+			assert.Len(t, client.Inputs(), tt.wantInputs)
+		})
+	}
+}
+
+func TestWriterFlushResultHook(t *testing.T) {
+	tests := []struct {
+		name   string
+		client testKinesisClient
+		want   kinesiswriter.FlushResult
+	}{
+		{
+			name:   "retried",
+			client: &partialFailedKinesisClient{},
+			want:   kinesiswriter.FlushResult{Sent: 4, Retries: 2, ByteSize: 28},
+		},
+		{
+			name:   "failed",
+			client: &errorKinesisClient{err: &smithy.GenericAPIError{Code: "AccessDeniedException"}},
+			want: kinesiswriter.FlushResult{
+				Failed:       4,
+				ByteSize:     28,
+				PerErrorCode: map[string]int{"AccessDeniedException": 4},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var results []kinesiswriter.FlushResult
+			writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+				kinesiswriter.WithKinesisClient(tt.client),
+				kinesiswriter.WithImmediateFlush(),
+				kinesiswriter.WithRetryBackoffByErrorCode(map[string]kinesiswriter.RetryBackoff{
+					"error": {MinDelay: time.Millisecond, MaxDelay: time.Millisecond},
+				}),
+				kinesiswriter.WithFlushResultHook(func(result kinesiswriter.FlushResult) {
+					results = append(results, result)
+				}),
+			)
+			require.NoError(t, err)
+			_, _ = writer.Write([]byte("record1\nrecord2\nrecord3\nrecord4\n"))
+			require.NoError(t, writer.Close())
+
+			require.Len(t, results, 1)
+			assert.Positive(t, results[0].Duration)
+			if diff := cmp.Diff(tt.want, results[0], cmpopts.IgnoreFields(kinesiswriter.FlushResult{}, "Duration")); diff != "" {
+				t.Errorf("unexpected result (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+type countingRetrier struct {
+	attempts []int
+	max      int
+}
+
+func (r *countingRetrier) NextDelay(attempt int, errorCodes []string) (time.Duration, bool) {
+	r.attempts = append(r.attempts, attempt)
+	return time.Millisecond, attempt <= r.max
+}
+
+func TestWriterRetrier(t *testing.T) {
+	client := &errorKinesisClient{err: &smithy.GenericAPIError{Code: "InternalFailure"}}
+	retrier := &countingRetrier{max: 1}
+	writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithImmediateFlush(),
+		kinesiswriter.WithRetrier(retrier),
+	)
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("record1\n"))
+	assert.Error(t, err)
+	require.NoError(t, writer.Close())
+
+	assert.Equal(t, []int{1, 2}, retrier.attempts)
+	assert.Len(t, client.Inputs(), 2)
+}
+
+func TestWriterClosePolicy(t *testing.T) {
+	t.Run("discard", func(t *testing.T) {
+		client := &successKinesisClient{}
+		var discarded []string
+		writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+			kinesiswriter.WithKinesisClient(client),
+			kinesiswriter.WithBufferErrorHandler(func(err error, records [][]byte) {
+				assert.ErrorIs(t, err, kinesiswriter.ErrDiscarded)
+				for _, record := range records {
+					discarded = append(discarded, string(record))
+				}
+			}),
+			kinesiswriter.WithClosePolicy(kinesiswriter.ClosePolicy{Discard: true}),
+		)
+		require.NoError(t, err)
+		_, err = writer.Write([]byte("record1\nrecord2\n"))
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+
+		assert.Equal(t, []string{"record1", "record2"}, discarded)
+		assert.Empty(t, client.Inputs())
+	})
+	t.Run("retrier", func(t *testing.T) {
+		client := &errorKinesisClient{err: &smithy.GenericAPIError{Code: "InternalFailure"}}
+		retrier := &countingRetrier{max: 0}
+		writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+			kinesiswriter.WithKinesisClient(client),
+			kinesiswriter.WithBufferErrorHandler(func(error, [][]byte) {}),
+			kinesiswriter.WithClosePolicy(kinesiswriter.ClosePolicy{Retrier: retrier}),
+		)
+		require.NoError(t, err)
+		_, err = writer.Write([]byte("record1\n"))
+		require.NoError(t, err)
+		_ = writer.Close()
+
+		assert.Equal(t, []int{1}, retrier.attempts)
+		assert.Len(t, client.Inputs(), 1)
+	})
+}
+
+func TestWriterSingleLine(t *testing.T) {
+	client := &successKinesisClient{}
+	writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithImmediateFlush(),
+	)
+	require.NoError(t, err)
+	for _, p := range []string{"record1\n", "record2\r\n", "record3", "\n", "", "record4\nrecord5"} {
+		buf := []byte(p)
+		n, err := writer.Write(buf)
+		require.NoError(t, err)
+		assert.Equal(t, len(p), n)
+		// the writer must not retain p.
+		for i := range buf {
+			buf[i] = 'x'
+		}
+	}
+	require.NoError(t, writer.Close())
+
+	var records []string
+	for _, input := range client.Inputs() {
+		for _, entry := range input.Records {
+			records = append(records, string(entry.Data))
+		}
+	}
+	assert.Equal(t, []string{"record1", "record2", "record3", "record4", "record5"}, records)
+}
+
+func TestWriterEmptyRecords(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []kinesiswriter.WriterConfigOption
+		want []string
+	}{
+		{name: "skip by default", want: []string{"record1", "record2"}},
+		{
+			name: "allow",
+			opts: []kinesiswriter.WriterConfigOption{kinesiswriter.WithAllowEmptyRecords()},
+			want: []string{"record1", "", "record2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &successKinesisClient{}
+			opts := append([]kinesiswriter.WriterConfigOption{
+				kinesiswriter.WithKinesisClient(client),
+				kinesiswriter.WithImmediateFlush(),
+			}, tt.opts...)
+			writer, err := kinesiswriter.New(context.Background(), testStreamARN, opts...)
+			require.NoError(t, err)
+			_, err = writer.Write([]byte("record1\n\nrecord2\n"))
+			require.NoError(t, err)
+			require.NoError(t, writer.Close())
+
+			var records []string
+			for _, input := range client.Inputs() {
+				for _, entry := range input.Records {
+					records = append(records, string(entry.Data))
+				}
+			}
+			assert.Equal(t, tt.want, records)
+		})
+	}
+}
+
+func TestWriterLineFraming(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   []kinesiswriter.WriterConfigOption
+		writes []string
+		want   []string
+	}{
+		{
+			name:   "default",
+			writes: []string{"record1\r\nrec", "ord2\n"},
+			want:   []string{"record1", "rec", "ord2"},
+		},
+		{
+			name:   "keep carriage return",
+			opts:   []kinesiswriter.WriterConfigOption{kinesiswriter.WithKeepCarriageReturn()},
+			writes: []string{"record1\r\nrecord2\r\n"},
+			want:   []string{"record1\r", "record2\r"},
+		},
+		{
+			name:   "hold partial line",
+			opts:   []kinesiswriter.WriterConfigOption{kinesiswriter.WithHoldPartialLine()},
+			writes: []string{"record1\nrec", "ord", "2\nrecord3"},
+			want:   []string{"record1", "record2", "record3"},
+		},
+		{
+			name:   "logging profile",
+			opts:   []kinesiswriter.WriterConfigOption{kinesiswriter.WithProfile(kinesiswriter.ProfileLogging)},
+			writes: []string{"record1\nrec", "ord2\n"},
+			want:   []string{"record1", "record2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &successKinesisClient{}
+			opts := append([]kinesiswriter.WriterConfigOption{
+				kinesiswriter.WithKinesisClient(client),
+				kinesiswriter.WithImmediateFlush(),
+			}, tt.opts...)
+			writer, err := kinesiswriter.New(context.Background(), testStreamARN, opts...)
+			require.NoError(t, err)
+			for _, p := range tt.writes {
+				n, err := writer.Write([]byte(p))
+				require.NoError(t, err)
+				assert.Equal(t, len(p), n)
+			}
+			require.NoError(t, writer.Close())
+
+			var records []string
+			for _, input := range client.Inputs() {
+				for _, entry := range input.Records {
+					records = append(records, string(entry.Data))
+				}
+			}
+			assert.Equal(t, tt.want, records)
+		})
+	}
+}
+
+func TestWriterClone(t *testing.T) {
+	client := &successKinesisClient{}
+	base, err := kinesiswriter.New(context.Background(), stream1ARN,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithImmediateFlush(),
+	)
+	require.NoError(t, err)
+	clone, err := base.Clone(kinesiswriter.WithStreamARN(stream2ARN))
+	require.NoError(t, err)
+	assert.Equal(t, stream2ARN, clone.StreamARN().String())
+
+	_, err = base.Write([]byte("record1"))
+	require.NoError(t, err)
+	_, err = clone.Write([]byte("record2"))
+	require.NoError(t, err)
+	require.NoError(t, base.Close())
+	require.NoError(t, clone.Close())
+
+	require.Len(t, client.Inputs(), 2)
+	assert.Equal(t, stream1ARN, aws.ToString(client.Inputs()[0].StreamARN))
+	assert.Equal(t, stream2ARN, aws.ToString(client.Inputs()[1].StreamARN))
+
+	_, err = base.Clone(kinesiswriter.WithStreamARN("invalid"))
+	var arnErr *kinesiswriter.InvalidARNError
+	assert.ErrorAs(t, err, &arnErr)
+}
+
+func TestWriterTenants(t *testing.T) {
+	client := &successKinesisClient{}
+	var rejected []string
+	writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithImmediateFlush(),
+		kinesiswriter.WithTenantKey(func(record []byte) string {
+			tenant, _, _ := bytes.Cut(record, []byte(":"))
+			return string(tenant)
+		}),
+		kinesiswriter.WithTenantRateLimit(0, 2),
+		kinesiswriter.WithRejectHandler(func(err error, record []byte) {
+			assert.ErrorIs(t, err, kinesiswriter.ErrTenantRateLimited)
+			rejected = append(rejected, string(record))
+		}),
+	)
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("a:1\nb:1\na:2\na:3\n"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	assert.Equal(t, []string{"a:3"}, rejected)
+	require.Len(t, client.Inputs(), 1)
+	var keys []string
+	for _, entry := range client.Inputs()[0].Records {
+		keys = append(keys, aws.ToString(entry.PartitionKey))
+	}
+	assert.Equal(t, []string{"a", "b", "a"}, keys)
+	assert.Equal(t, map[string]kinesiswriter.TenantStats{
+		"a": {RecordsSent: 2, BytesSent: 6, RateLimited: 1},
+		"b": {RecordsSent: 1, BytesSent: 3},
+	}, writer.Stats().Tenants)
+}
+
+func TestWriterStreamKeyFunc(t *testing.T) {
+	for _, immediate := range []bool{true, false} {
+		t.Run(fmt.Sprintf("immediate=%t", immediate), func(t *testing.T) {
+			client := &successKinesisClient{}
+			opts := []kinesiswriter.WriterConfigOption{
+				kinesiswriter.WithKinesisClient(client),
+				kinesiswriter.WithStreamKeyFunc(func(record []byte) string {
+					key, _, _ := bytes.Cut(record, []byte(":"))
+					return string(key)
+				}, map[string]string{"one": stream1ARN, "two": stream2ARN}),
+			}
+			if immediate {
+				opts = append(opts, kinesiswriter.WithImmediateFlush())
+			}
+			writer, err := kinesiswriter.New(context.Background(), testStreamARN, opts...)
+			require.NoError(t, err)
+			_, err = writer.Write([]byte("one:1\nother:1\ntwo:1\none:2\n"))
+			require.NoError(t, err)
+			require.NoError(t, writer.Close())
+
+			got := map[string][]string{}
+			for _, input := range client.Inputs() {
+				for _, entry := range input.Records {
+					got[aws.ToString(input.StreamARN)] = append(got[aws.ToString(input.StreamARN)], string(entry.Data))
+				}
+			}
+			assert.Equal(t, map[string][]string{
+				testStreamARN: {"other:1"},
+				stream1ARN:    {"one:1", "one:2"},
+				stream2ARN:    {"two:1"},
+			}, got)
+			assert.Equal(t, int64(4), writer.Stats().RecordsSent)
+		})
+	}
+
+	var buffers []*sliceBuffer
+	_, err := kinesiswriter.New(context.Background(), testStreamARN,
+		kinesiswriter.WithKinesisClient(&successKinesisClient{}),
+		kinesiswriter.WithStreamKeyFunc(func([]byte) string { return "" }, map[string]string{"a": stream1ARN, "bad": "invalid"}),
+		kinesiswriter.WithBuffer(func(flush func([]kinesiswriter.Record) error) kinesiswriter.Buffer {
+			b := &sliceBuffer{flush: flush}
+			buffers = append(buffers, b)
+			return b
+		}),
+	)
+	assert.Error(t, err)
+	require.NotEmpty(t, buffers)
+	for _, b := range buffers {
+		assert.True(t, b.closed, "buffers are closed when the routes fail")
+	}
+}
+
+func TestWriterWait(t *testing.T) {
+	client := &lockedKinesisClient{}
+	writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithBufferFlushInterval(time.Hour),
+	)
+	require.NoError(t, err)
+	defer writer.Close()
+	_, err = writer.Write([]byte("record1\nrecord2\n"))
+	require.NoError(t, err)
+
+	require.NoError(t, writer.Wait(context.Background()))
+	var sent int
+	for _, input := range client.Inputs() {
+		sent += len(input.Records)
+	}
+	assert.Equal(t, 2, sent)
+	assert.Zero(t, writer.Len())
+
+	_, err = writer.Write([]byte("record3\n"))
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, writer.Wait(ctx), context.Canceled)
+}
+
+func TestWriterShutdown(t *testing.T) {
+	client := &lockedKinesisClient{}
+	writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithBufferFlushInterval(time.Hour),
+		kinesiswriter.WithHoldPartialLine(),
+	)
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("record1\nrecord2"))
+	require.NoError(t, err)
+
+	require.NoError(t, writer.Shutdown())
+	_, err = writer.Write([]byte("record3\n"))
+	assert.ErrorIs(t, err, kinesiswriter.ErrWriterClosed)
+	assert.ErrorIs(t, writer.Shutdown(), kinesiswriter.ErrWriterClosed)
+	err = writer.WriteEntries([]types.PutRecordsRequestEntry{{Data: []byte("record4"), PartitionKey: aws.String("key")}})
+	assert.ErrorIs(t, err, kinesiswriter.ErrWriterClosed)
+
+	require.NoError(t, writer.Wait(context.Background()))
+	var sent []string
+	for _, input := range client.Inputs() {
+		for _, entry := range input.Records {
+			sent = append(sent, string(entry.Data))
+		}
+	}
+	assert.ElementsMatch(t, []string{"record1", "record2"}, sent)
+	require.NoError(t, writer.Close())
+	assert.ErrorIs(t, writer.Shutdown(), kinesiswriter.ErrWriterClosed)
+}
+
+func TestWriterFlushSync(t *testing.T) {
+	client := &lockedKinesisClient{}
+	writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithBufferFlushInterval(time.Hour),
+	)
+	require.NoError(t, err)
+	defer writer.Close()
+	_, err = writer.Write([]byte("record1\n"))
+	require.NoError(t, err)
+	require.NoError(t, writer.FlushSync())
+	require.Len(t, client.Inputs(), 1)
+	assert.Equal(t, []byte("record1"), client.Inputs()[0].Records[0].Data)
+}
+
+func TestWriterConcurrentUse(t *testing.T) {
+	for _, name := range []string{"buffered", "immediate"} {
+		t.Run(name, func(t *testing.T) {
+			client := &lockedKinesisClient{}
+			opts := []kinesiswriter.WriterConfigOption{
+				kinesiswriter.WithKinesisClient(client),
+				kinesiswriter.WithBufferFlushInterval(time.Millisecond),
+			}
+			if name == "immediate" {
+				opts = append(opts, kinesiswriter.WithImmediateFlush())
+			}
+			writer, err := kinesiswriter.New(context.Background(), testStreamARN, opts...)
+			require.NoError(t, err)
+
+			var (
+				wg       sync.WaitGroup
+				accepted atomic.Int64
+				start    = make(chan struct{})
+			)
+			for g := range 8 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					<-start
+					for i := range 200 {
+						if _, err := writer.Write([]byte(fmt.Sprintf("record-%d-%d\n", g, i))); err != nil {
+							assert.ErrorIs(t, err, kinesiswriter.ErrWriterClosed)
+							return
+						}
+						accepted.Add(1)
+						if i%50 == 0 {
+							_ = writer.Sync()
+						}
+					}
+				}()
+			}
+			close(start)
+			time.Sleep(5 * time.Millisecond)
+			require.NoError(t, writer.Close())
+			wg.Wait()
+
+			var sent int64
+			for _, input := range client.Inputs() {
+				sent += int64(len(input.Records))
+			}
+			assert.Equal(t, accepted.Load(), sent)
+			assert.ErrorIs(t, writer.Close(), kinesiswriter.ErrWriterClosed)
+			assert.ErrorIs(t, writer.Sync(), kinesiswriter.ErrWriterClosed)
+		})
+	}
+}
+
+func TestWriterWriteEntries(t *testing.T) {
+	client := &successKinesisClient{}
+	var rejected []error
+	writer, err := kinesiswriter.New(context.Background(), testStreamARN,
+		kinesiswriter.WithKinesisClient(client),
+		kinesiswriter.WithManualFlush(),
+		kinesiswriter.WithRejectHandler(func(err error, record []byte) {
+			rejected = append(rejected, err)
+		}),
+	)
+	require.NoError(t, err)
+	err = writer.WriteEntries([]types.PutRecordsRequestEntry{
+		{Data: []byte("record1"), PartitionKey: aws.String("key1")},
+		{Data: []byte("record2"), PartitionKey: aws.String("key2"), ExplicitHashKey: aws.String("42")},
+		{Data: []byte("record3")},
+		{Data: []byte("record4"), PartitionKey: aws.String(strings.Repeat("k", 257))},
+	})
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	assert.Equal(t, []error{kinesiswriter.ErrInvalidPartitionKey}, rejected)
+	require.Len(t, client.Inputs(), 1)
+	entries := client.Inputs()[0].Records
+	require.Len(t, entries, 3)
+	assert.Equal(t, "key1", aws.ToString(entries[0].PartitionKey))
+	assert.Nil(t, entries[0].ExplicitHashKey)
+	assert.Equal(t, "key2", aws.ToString(entries[1].PartitionKey))
+	assert.Equal(t, "42", aws.ToString(entries[1].ExplicitHashKey))
+	assert.NotEmpty(t, aws.ToString(entries[2].PartitionKey))
+	assert.ErrorIs(t, writer.WriteEntries(entries), kinesiswriter.ErrWriterClosed)
+}